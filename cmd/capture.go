@@ -0,0 +1,201 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var captureLogsDirName = "runs"
+
+// Units sizes of captured outputs can be given in
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// Parses a size such as 512KB or 1MB. Sizes without a unit are in bytes.
+func parseSize(size string) (int64, error) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(size, u.suffix) {
+			size, unit = strings.TrimSpace(strings.TrimSuffix(size, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("use a size such as 512KB or 1MB")
+	}
+	return n * unit, nil
+}
+
+// Checks a size setting
+func checkSize(value string) error {
+	_, err := parseSize(value)
+	return err
+}
+
+// Checks a setting holding a number of things
+func checkCount(value string) error {
+	if n, err := strconv.Atoi(value); err != nil || n < 0 {
+		return fmt.Errorf("use a number which is not negative")
+	}
+	return nil
+}
+
+// Checks an age setting
+func checkAge(value string) error {
+	if d, err := parseAge(value); err != nil || d <= 0 {
+		return fmt.Errorf("use an age such as 12h, 30d or 2w")
+	}
+	return nil
+}
+
+// Writer keeping the last bytes written to it, up to a maximum size.
+// Output of a command and its errors can be written to it at the same time.
+type captureWriter struct {
+	mu      sync.Mutex
+	max     int
+	data    []byte
+	dropped int64
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.data = append(w.data, p...)
+	// trim only once twice the maximum is held, so bytes are not moved on
+	// every write
+	if len(w.data) > 2*w.max {
+		cut := len(w.data) - w.max
+		w.dropped += int64(cut)
+		w.data = append(w.data[:0], w.data[cut:]...)
+	}
+	return len(p), nil
+}
+
+// Returns captured output, noting how much of its beginning is cut
+func (w *captureWriter) Bytes() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data, dropped := w.data, w.dropped
+	if len(data) > w.max {
+		dropped += int64(len(data) - w.max)
+		data = data[len(data)-w.max:]
+	}
+	if dropped == 0 {
+		return append([]byte(nil), data...)
+	}
+	return append([]byte(fmt.Sprintf("[first %d bytes are cut]\n", dropped)), data...)
+}
+
+// Returns a writer capturing output of a run, cut to capture.max_size
+func newCaptureWriter() (*captureWriter, error) {
+	maxSize, err := parseSize(viper.GetString("capture.max_size"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid capture.max_size: %v", err)
+	}
+	return &captureWriter{max: int(maxSize)}, nil
+}
+
+// Returns path of the file captured output of run is kept in
+func getCaptureLogPath(runID int) (string, error) {
+	stateDirPath, err := getStateDirPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDirPath, captureLogsDirName, strconv.Itoa(runID)+".log"), nil
+}
+
+// Saves captured output of run, then removes the captured outputs which are
+// over the limits of capture.max_logs and capture.max_age
+func saveCapturedOutput(run Run, capture *captureWriter) error {
+	logPath, err := getCaptureLogPath(run.ID)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(logPath, capture.Bytes(), 0644); err != nil {
+		return err
+	}
+	return pruneCapturedOutputs(time.Now())
+}
+
+// Removes captured outputs older than capture.max_age, then the oldest ones
+// until capture.max_logs are left. No limit is set on the number of outputs
+// when capture.max_logs is 0.
+func pruneCapturedOutputs(now time.Time) error {
+	maxLogs, err := strconv.Atoi(viper.GetString("capture.max_logs"))
+	if err != nil {
+		return fmt.Errorf("invalid capture.max_logs: %v", err)
+	}
+	maxAge, err := parseAge(viper.GetString("capture.max_age"))
+	if err != nil {
+		return fmt.Errorf("invalid capture.max_age: %v", err)
+	}
+	logPath, err := getCaptureLogPath(0)
+	if err != nil {
+		return err
+	}
+	files, err := ioutil.ReadDir(filepath.Dir(logPath))
+	if err != nil {
+		return err
+	}
+	var logs []os.FileInfo
+	for _, file := range files {
+		if !file.IsDir() && filepath.Ext(file.Name()) == ".log" {
+			logs = append(logs, file)
+		}
+	}
+	// newest first
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].ModTime().After(logs[j].ModTime())
+	})
+	for i, log := range logs {
+		if (maxLogs == 0 || i < maxLogs) && now.Sub(log.ModTime()) <= maxAge {
+			continue
+		}
+		if err = os.Remove(filepath.Join(filepath.Dir(logPath), log.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size  string
+		bytes int64
+		valid bool
+	}{
+		{"100", 100, true},
+		{"100B", 100, true},
+		{"512KB", 512 << 10, true},
+		{"1MB", 1 << 20, true},
+		{" 2 mb ", 2 << 20, true},
+		{"1GB", 1 << 30, true},
+		{"0", 0, false},
+		{"-1KB", 0, false},
+		{"1.5MB", 0, false},
+		{"MB", 0, false},
+		{"1TB", 0, false},
+	}
+	for _, test := range tests {
+		bytes, err := parseSize(test.size)
+		if (err == nil) != test.valid || bytes != test.bytes {
+			t.Errorf("parseSize(%q) = %d (%v), want %d", test.size, bytes, err, test.bytes)
+		}
+	}
+}
+
+func TestCaptureWriter(t *testing.T) {
+	tests := []struct {
+		max    int
+		writes []string
+		want   string
+	}{
+		{10, []string{"hello"}, "hello"},
+		{10, []string{"hello", "world"}, "helloworld"},
+		{10, []string{"hello", "world", "!"}, "[first 1 bytes are cut]\nelloworld!"},
+		{4, []string{"0123456789", "abc"}, "[first 9 bytes are cut]\n9abc"},
+		{4, []string{"01", "23", "45", "67", "89", "ab"}, "[first 8 bytes are cut]\n89ab"},
+	}
+	for _, test := range tests {
+		capture := &captureWriter{max: test.max}
+		for _, write := range test.writes {
+			if n, err := capture.Write([]byte(write)); n != len(write) || err != nil {
+				t.Fatalf("Write(%q) = %d, %v", write, n, err)
+			}
+		}
+		if captured := string(capture.Bytes()); captured != test.want {
+			t.Errorf("capturing %q in %d bytes = %q, want %q", test.writes, test.max, captured, test.want)
+		}
+	}
+}
+
+func TestPruneCapturedOutputs(t *testing.T) {
+	now := time.Now()
+	// ages of captured outputs of runs 1 to 5
+	ages := []time.Duration{50 * time.Hour, 30 * time.Hour, 3 * time.Hour, 2 * time.Hour, time.Hour}
+	tests := []struct {
+		maxLogs string
+		maxAge  string
+		kept    []string
+	}{
+		{"100", "30d", []string{"1.log", "2.log", "3.log", "4.log", "5.log"}},
+		{"2", "30d", []string{"4.log", "5.log"}},
+		{"0", "30d", []string{"1.log", "2.log", "3.log", "4.log", "5.log"}},
+		{"0", "1d", []string{"3.log", "4.log", "5.log"}},
+		{"2", "1d", []string{"4.log", "5.log"}},
+		{"4", "2d", []string{"2.log", "3.log", "4.log", "5.log"}},
+	}
+	for _, test := range tests {
+		t.Setenv(homeEnvVariable, t.TempDir())
+		viper.Set("capture.max_logs", test.maxLogs)
+		viper.Set("capture.max_age", test.maxAge)
+		for i, age := range ages {
+			path, err := getCaptureLogPath(i + 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err = ioutil.WriteFile(path, []byte(fmt.Sprintln("run", i+1)), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err = os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := pruneCapturedOutputs(now); err != nil {
+			t.Fatal(err)
+		}
+		dir, _ := getCaptureLogPath(0)
+		files, err := ioutil.ReadDir(filepath.Dir(dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var kept []string
+		for _, file := range files {
+			kept = append(kept, file.Name())
+		}
+		sort.Strings(kept)
+		if !reflect.DeepEqual(kept, test.kept) {
+			t.Errorf("max_logs %s, max_age %s kept %q, want %q", test.maxLogs, test.maxAge, kept, test.kept)
+		}
+	}
+	viper.Set("capture.max_logs", nil)
+	viper.Set("capture.max_age", nil)
+}
+
+func TestSaveCapturedOutput(t *testing.T) {
+	t.Setenv(homeEnvVariable, t.TempDir())
+	viper.Set("capture.max_size", "8B")
+	viper.Set("capture.max_logs", "100")
+	viper.Set("capture.max_age", "30d")
+	defer func() {
+		viper.Set("capture.max_size", nil)
+		viper.Set("capture.max_logs", nil)
+		viper.Set("capture.max_age", nil)
+	}()
+
+	capture, err := newCaptureWriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	capture.Write([]byte("starting\ndone\n"))
+	if err = saveCapturedOutput(Run{ID: 7}, capture); err != nil {
+		t.Fatal(err)
+	}
+	path, err := getCaptureLogPath(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[first 6 bytes are cut]\nng\ndone\n"; string(saved) != want {
+		t.Errorf("saved %q, want %q", saved, want)
+	}
+
+	viper.Set("capture.max_size", "lots")
+	if _, err = newCaptureWriter(); err == nil || !strings.Contains(err.Error(), "capture.max_size") {
+		t.Errorf("newCaptureWriter() with an invalid size gave %v", err)
+	}
+}
@@ -0,0 +1,345 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+const configEnvPrefix = "KATIP"
+
+// A setting of the config file
+type configSetting struct {
+	key          string
+	defaultValue string
+	values       []string // allowed values, any value is allowed if empty
+	check        func(value string) error
+	description  string
+}
+
+// Settings katip reads from the config file. Each of them can be overridden
+// by an environment variable, KATIP_SYNC_BACKEND for sync.backend.
+var configSettings = []configSetting{
+	{key: "editor", description: "editor used by edit commands, $VISUAL or $EDITOR if empty, then vim"},
+	{key: "shell", defaultValue: "bash", description: "shell commands without an interpreter are run with, a name or an argv such as \"zsh -o pipefail -c\""},
+	{key: "confirm", defaultValue: "always", values: []string{"always", "never"}, description: "ask before running a command"},
+	{key: "color", defaultValue: "auto", values: []string{"auto", "always", "never"}, description: "colorize output"},
+	{key: "output.format", description: "template or template name list and grep print commands with, a table if empty"},
+	{key: "search.mode", defaultValue: "regex", values: []string{"regex", "substring", "fuzzy"}, description: "how grep and run match commands"},
+	{key: "library", defaultValue: defaultLibrary, description: "library in use"},
+	{key: "history.max_runs", defaultValue: "1000", check: checkCount, description: "number of runs kept in run history, older ones are removed, 0 for no limit"},
+	{key: "capture.max_size", defaultValue: "1MB", check: checkSize, description: "size the captured output of a run is cut to, keeping its end"},
+	{key: "capture.max_logs", defaultValue: "100", check: checkCount, description: "number of captured outputs kept, older ones are removed, 0 for no limit"},
+	{key: "capture.max_age", defaultValue: "30d", check: checkAge, description: "how long captured outputs are kept, such as 12h, 30d or 2w"},
+	{key: "store.backend", defaultValue: "file", values: []string{"file", gitStoreBackend}, description: "how the commands file is stored"},
+	{key: "store.git.remote", description: "remote URL of the git store"},
+	{key: "store.git.branch", defaultValue: defaultGitStoreBranch, description: "branch of the git store"},
+	{key: "sync.backend", defaultValue: "gist", values: []string{"gist", "webdav", "s3"}, description: "remote synced with sync push and pull"},
+	{key: "sync.gist.id", description: "ID of the gist commands are synced to, created on first push if empty"},
+	{key: "sync.gist.url", defaultValue: defaultGistAPIURL, description: "GitHub API URL"},
+	{key: "sync.gist.token_env", defaultValue: defaultGistTokenEnv, description: "environment variable holding the GitHub token"},
+	{key: "sync.webdav.url", description: "WebDAV URL of the commands file, or of a collection if it ends with /"},
+	{key: "sync.webdav.username", description: "WebDAV username"},
+	{key: "sync.webdav.password_env", defaultValue: "KATIP_WEBDAV_PASSWORD", description: "environment variable holding the WebDAV password"},
+	{key: "sync.s3.endpoint", description: "endpoint of an S3 compatible storage, AWS if empty"},
+	{key: "sync.s3.bucket", description: "S3 bucket"},
+	{key: "sync.s3.key", description: "S3 object key, katip/<library file> if empty"},
+	{key: "sync.s3.region", defaultValue: defaultS3Region, description: "S3 region"},
+	{key: "sync.s3.access_key_env", defaultValue: "AWS_ACCESS_KEY_ID", description: "environment variable holding the S3 access key"},
+	{key: "sync.s3.secret_key_env", defaultValue: "AWS_SECRET_ACCESS_KEY", description: "environment variable holding the S3 secret key"},
+}
+
+// Settings which hold a map of user defined keys rather than a single value
+var configMapSettings = []string{"templates"}
+
+// Registers defaults of settings and environment variable overrides
+func setConfigDefaults() {
+	viper.SetEnvPrefix(configEnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+	for _, setting := range configSettings {
+		viper.SetDefault(setting.key, setting.defaultValue)
+	}
+}
+
+// Returns the setting with key
+func findConfigSetting(key string) (configSetting, bool) {
+	for _, setting := range configSettings {
+		if setting.key == key {
+			return setting, true
+		}
+	}
+	return configSetting{}, false
+}
+
+// Checks if key is a setting or a key of a map setting
+func isKnownConfigKey(key string) bool {
+	if _, ok := findConfigSetting(key); ok {
+		return true
+	}
+	for _, mapSetting := range configMapSettings {
+		if key == mapSetting || strings.HasPrefix(key, mapSetting+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns an error if value is not allowed for setting
+func validateConfigValue(setting configSetting, value string) error {
+	if len(setting.values) > 0 && !isStringInSlice(value, setting.values) {
+		return fmt.Errorf("invalid %s %q (use %s)", setting.key, value, strings.Join(setting.values, ", "))
+	}
+	if setting.check != nil {
+		if err := setting.check(value); err != nil {
+			return fmt.Errorf("invalid %s %q: %v", setting.key, value, err)
+		}
+	}
+	return nil
+}
+
+// Reads only the config file, without defaults and environment variables
+func readConfigFile(path string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return v, nil
+	}
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Returns path of the config file in use
+func getConfigFileUsed() (string, error) {
+	if configFile := viper.ConfigFileUsed(); configFile != "" {
+		return configFile, nil
+	}
+	return getConfigFilePath()
+}
+
+// Checks the config file and returns its problems. Unknown keys are reported
+// but are not errors, they may belong to a newer version.
+func validateConfigFile(path string) (problems []string, valid bool, err error) {
+	v, err := readConfigFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	valid = true
+	for _, key := range v.AllKeys() {
+		if !isKnownConfigKey(key) {
+			problems = append(problems, fmt.Sprintf("unknown setting %s", key))
+			continue
+		}
+		setting, ok := findConfigSetting(key)
+		if !ok {
+			continue
+		}
+		if err := validateConfigValue(setting, v.GetString(key)); err != nil {
+			problems = append(problems, err.Error())
+			valid = false
+		}
+	}
+	for _, name := range configMapSettings {
+		if _, isMap := v.Get(name).(map[string]interface{}); v.IsSet(name) && !isMap {
+			problems = append(problems, fmt.Sprintf("%s must be a map", name))
+			valid = false
+		}
+	}
+	return problems, valid, nil
+}
+
+// Returns the editor command and its arguments
+func getEditorCommand() []string {
+	for _, editor := range []string{viper.GetString("editor"), os.Getenv("VISUAL"), os.Getenv("EDITOR")} {
+		if fields := strings.Fields(editor); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{"vim"}
+}
+
+// Opens path in the configured editor
+func openInEditor(path string) error {
+	editorCommand := getEditorCommand()
+	editor := exec.Command(editorCommand[0], append(editorCommand[1:], path)...)
+	editor.Stdin = os.Stdin
+	editor.Stdout = os.Stdout
+	editor.Stderr = os.Stderr
+	return editor.Run()
+}
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Shows and changes settings",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get KEY",
+	Short: "Prints value of a setting",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := strings.ToLower(args[0])
+		if !isKnownConfigKey(key) {
+			fmt.Println("unknown setting:", args[0])
+			return
+		}
+		if _, ok := findConfigSetting(key); ok {
+			fmt.Println(viper.GetString(key))
+			return
+		}
+		out, err := yaml.Marshal(viper.Get(key))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Print(string(out))
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set KEY VALUE",
+	Short: "Changes a setting in the config file",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := strings.ToLower(args[0])
+		if !isKnownConfigKey(key) {
+			fmt.Println("unknown setting:", args[0])
+			return
+		}
+		if setting, ok := findConfigSetting(key); ok {
+			if err := validateConfigValue(setting, args[1]); err != nil {
+				fmt.Println(err)
+				return
+			}
+		} else if !strings.Contains(key, ".") {
+			fmt.Printf("%s is a map, set one of its keys such as %s.name\n", key, key)
+			return
+		}
+		if err := saveConfigValue(key, args[1]); err != nil {
+			fmt.Println("error while saving config:", err)
+		}
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists settings with their current values",
+	Run: func(cmd *cobra.Command, args []string) {
+		width := 0
+		for _, setting := range configSettings {
+			if len(setting.key) > width {
+				width = len(setting.key)
+			}
+		}
+		for _, setting := range configSettings {
+			fmt.Printf("%-*s = %s\n", width, setting.key, viper.GetString(setting.key))
+			if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+				fmt.Printf("%-*s   # %s\n", width, "", setting.description)
+			}
+		}
+		for _, name := range configMapSettings {
+			values := viper.GetStringMapString(name)
+			for _, key := range sortedKeys(values) {
+				fmt.Printf("%s.%s = %s\n", name, key, values[key])
+			}
+		}
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Opens the config file in your editor",
+	Run: func(cmd *cobra.Command, args []string) {
+		configFile, err := getConfigFileUsed()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err = os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+			fmt.Println("error while creating config directory:", err)
+			return
+		}
+		if _, err = os.Stat(configFile); os.IsNotExist(err) {
+			if err = ioutil.WriteFile(configFile, nil, 0644); err != nil {
+				fmt.Println("error while creating config file:", err)
+				return
+			}
+		}
+		if err = openInEditor(configFile); err != nil {
+			fmt.Println("error occured while opening editor:", err)
+			return
+		}
+		problems, _, err := validateConfigFile(configFile)
+		if err != nil {
+			fmt.Println("config file is invalid:", err)
+			return
+		}
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Checks the config file for unknown settings and invalid values",
+	Run: func(cmd *cobra.Command, args []string) {
+		configFile, err := getConfigFileUsed()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		problems, valid, err := validateConfigFile(configFile)
+		if err != nil {
+			fmt.Println("config file is invalid:", err)
+			os.Exit(1)
+		}
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if !valid {
+			os.Exit(1)
+		}
+		fmt.Println(configFile, "is valid")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd, configSetCmd, configListCmd, configEditCmd, configValidateCmd)
+	configListCmd.Flags().BoolP("verbose", "v", false, "describe each setting")
+}
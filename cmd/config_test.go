@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfigValue(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		valid bool
+	}{
+		{"confirm", "always", true},
+		{"confirm", "never", true},
+		{"confirm", "sometimes", false},
+		{"color", "auto", true},
+		{"color", "blue", false},
+		{"search.mode", "fuzzy", true},
+		{"search.mode", "glob", false},
+		{"history.max_runs", "0", true},
+		{"history.max_runs", "250", true},
+		{"history.max_runs", "-1", false},
+		{"history.max_runs", "many", false},
+		{"capture.max_size", "512KB", true},
+		{"capture.max_size", "1mb", true},
+		{"capture.max_size", "2048", true},
+		{"capture.max_size", "0", false},
+		{"capture.max_size", "1TB", false},
+		{"capture.max_age", "12h", true},
+		{"capture.max_age", "30d", true},
+		{"capture.max_age", "2w", true},
+		{"capture.max_age", "0d", false},
+		{"capture.max_age", "month", false},
+		{"editor", "anything goes", true},
+	}
+	for _, test := range tests {
+		setting, ok := findConfigSetting(test.key)
+		if !ok {
+			t.Fatalf("no setting %s", test.key)
+		}
+		err := validateConfigValue(setting, test.value)
+		if (err == nil) != test.valid {
+			t.Errorf("%s = %q: error %v, want valid %v", test.key, test.value, err, test.valid)
+		}
+	}
+}
+
+func TestIsKnownConfigKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		known bool
+	}{
+		{"shell", true},
+		{"sync.s3.bucket", true},
+		{"templates", true},
+		{"templates.short", true},
+		{"sync", false},
+		{"templatesx", false},
+		{"colour", false},
+	}
+	for _, test := range tests {
+		if known := isKnownConfigKey(test.key); known != test.known {
+			t.Errorf("isKnownConfigKey(%q) = %v, want %v", test.key, known, test.known)
+		}
+	}
+}
+
+func TestDefaultsAreValid(t *testing.T) {
+	for _, setting := range configSettings {
+		if setting.defaultValue == "" {
+			continue
+		}
+		if err := validateConfigValue(setting, setting.defaultValue); err != nil {
+			t.Errorf("default of %s: %v", setting.key, err)
+		}
+	}
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		valid    bool
+		problems []string
+	}{
+		{"missing", "", true, nil},
+		{"valid", "confirm: never\ncapture:\n  max_age: 2w\ntemplates:\n  short: '{{.Alias}}'\n", true, nil},
+		{"unknown", "colour: never\n", true, []string{"unknown setting colour"}},
+		{"invalid value", "color: blue\n", false, []string{`invalid color "blue"`}},
+		{"invalid map", "templates: short\n", false, []string{"templates must be a map"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if test.content != "" {
+				if err := ioutil.WriteFile(path, []byte(test.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			problems, valid, err := validateConfigFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if valid != test.valid {
+				t.Errorf("valid = %v, want %v (problems %q)", valid, test.valid, problems)
+			}
+			if len(problems) != len(test.problems) {
+				t.Fatalf("problems %q, want %q", problems, test.problems)
+			}
+			for i, problem := range test.problems {
+				if !strings.HasPrefix(problems[i], problem) {
+					t.Errorf("problem %q, want %q", problems[i], problem)
+				}
+			}
+		})
+	}
+}
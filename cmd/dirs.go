@@ -0,0 +1,140 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	appName              = "katip"
+	homeEnvVariable      = "KATIP_HOME"
+	configFileName       = "config.yaml"
+	legacyAppDirName     = ".katip"
+	legacyConfigFileName = ".katip.yaml"
+)
+
+// Returns directory of kind, such as data or state. KATIP_HOME holds every
+// kind of directory when set, otherwise the XDG base directory in env, or
+// fallback in home directory when env is not set, is used.
+func getBaseDirPath(kind, env, fallback string) (string, error) {
+	if katipHome := os.Getenv(homeEnvVariable); katipHome != "" {
+		return filepath.Join(katipHome, kind), nil
+	}
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appName), nil
+	}
+	homeDir, err := getHomeDirPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, fallback, appName), nil
+}
+
+// Returns application directory path, which holds commands
+func getAppDirPath() (string, error) {
+	return getBaseDirPath("data", "XDG_DATA_HOME", ".local/share")
+}
+
+// Returns directory of the config file
+func getConfigDirPath() (string, error) {
+	return getBaseDirPath("config", "XDG_CONFIG_HOME", ".config")
+}
+
+// Returns directory of files local to this machine, such as sync state
+func getStateDirPath() (string, error) {
+	return getBaseDirPath("state", "XDG_STATE_HOME", ".local/state")
+}
+
+// Returns directory of files which can be recreated any time
+func getCacheDirPath() (string, error) {
+	return getBaseDirPath("cache", "XDG_CACHE_HOME", ".cache")
+}
+
+// Returns path of the default config file
+func getConfigFilePath() (string, error) {
+	configDirPath, err := getConfigDirPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDirPath, configFileName), nil
+}
+
+// Moves ~/.katip and ~/.katip.yaml used by older versions to their XDG
+// locations, unless the new locations are already in use. Nothing is moved
+// into KATIP_HOME.
+func migrateLegacyDirs() error {
+	if os.Getenv(homeEnvVariable) != "" {
+		return nil
+	}
+	homeDir, err := getHomeDirPath()
+	if err != nil {
+		return err
+	}
+	appDirPath, err := getAppDirPath()
+	if err != nil {
+		return err
+	}
+	stateDirPath, err := getStateDirPath()
+	if err != nil {
+		return err
+	}
+	configFilePath, err := getConfigFilePath()
+	if err != nil {
+		return err
+	}
+	legacyAppDirPath := filepath.Join(homeDir, legacyAppDirName)
+	if moved, err := moveIfMissing(legacyAppDirPath, appDirPath); err != nil {
+		return err
+	} else if moved {
+		fmt.Fprintf(os.Stderr, "Moved %s to %s\n", legacyAppDirPath, appDirPath)
+		// sync state and conflicts are local to this machine
+		for _, name := range []string{syncDirName, conflictsFileName} {
+			if _, err := moveIfMissing(filepath.Join(appDirPath, name), filepath.Join(stateDirPath, name)); err != nil {
+				return err
+			}
+		}
+	}
+	legacyConfigFilePath := filepath.Join(homeDir, legacyConfigFileName)
+	if moved, err := moveIfMissing(legacyConfigFilePath, configFilePath); err != nil {
+		return err
+	} else if moved {
+		fmt.Fprintf(os.Stderr, "Moved %s to %s\n", legacyConfigFilePath, configFilePath)
+	}
+	return nil
+}
+
+// Renames source to target if source exists and target does not
+func moveIfMissing(source, target string) (bool, error) {
+	if _, err := os.Lstat(source); err != nil {
+		return false, nil
+	}
+	if _, err := os.Lstat(target); !os.IsNotExist(err) {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return false, err
+	}
+	return true, os.Rename(source, target)
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBaseDirs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, env := range []string{homeEnvVariable, "XDG_DATA_HOME", "XDG_CONFIG_HOME", "XDG_STATE_HOME", "XDG_CACHE_HOME"} {
+		t.Setenv(env, "")
+	}
+	dirs := []struct {
+		get      func() (string, error)
+		env      string
+		kind     string
+		fallback string
+	}{
+		{getAppDirPath, "XDG_DATA_HOME", "data", ".local/share/katip"},
+		{getConfigDirPath, "XDG_CONFIG_HOME", "config", ".config/katip"},
+		{getStateDirPath, "XDG_STATE_HOME", "state", ".local/state/katip"},
+		{getCacheDirPath, "XDG_CACHE_HOME", "cache", ".cache/katip"},
+	}
+	check := func(description string, get func() (string, error), want string) {
+		t.Helper()
+		if dir, err := get(); err != nil || dir != want {
+			t.Errorf("%s: %s (%v), want %s", description, dir, err, want)
+		}
+	}
+	for _, dir := range dirs {
+		check(dir.kind+" fallback", dir.get, filepath.Join(home, dir.fallback))
+		t.Setenv(dir.env, "relative/dir")
+		check(dir.kind+" with relative "+dir.env, dir.get, filepath.Join(home, dir.fallback))
+		t.Setenv(dir.env, "/xdg/"+dir.kind)
+		check(dir.kind+" with "+dir.env, dir.get, "/xdg/"+dir.kind+"/katip")
+		t.Setenv(homeEnvVariable, "/opt/katip")
+		check(dir.kind+" with "+homeEnvVariable, dir.get, "/opt/katip/"+dir.kind)
+		t.Setenv(homeEnvVariable, "")
+	}
+}
+
+func TestMigrateLegacyDirs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, env := range []string{homeEnvVariable, "XDG_DATA_HOME", "XDG_CONFIG_HOME", "XDG_STATE_HOME", "XDG_CACHE_HOME"} {
+		t.Setenv(env, "")
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(home, legacyAppDirName, commandsFileName), `{"commands": []}`)
+	write(filepath.Join(home, legacyAppDirName, conflictsFileName), "# conflicts")
+	write(filepath.Join(home, legacyConfigFileName), "shell: zsh\n")
+
+	if err := migrateLegacyDirs(); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		filepath.Join(home, ".local/share/katip", commandsFileName):  `{"commands": []}`,
+		filepath.Join(home, ".local/state/katip", conflictsFileName): "# conflicts",
+		filepath.Join(home, ".config/katip", configFileName):         "shell: zsh\n",
+	} {
+		if moved, err := ioutil.ReadFile(path); err != nil || string(moved) != content {
+			t.Errorf("%s is %q (%v), want %q", path, moved, err, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(home, legacyAppDirName)); !os.IsNotExist(err) {
+		t.Error("legacy directory is left behind")
+	}
+
+	// directories which are in use are never replaced
+	write(filepath.Join(home, legacyConfigFileName), "shell: fish\n")
+	if err := migrateLegacyDirs(); err != nil {
+		t.Fatal(err)
+	}
+	if config, _ := ioutil.ReadFile(filepath.Join(home, ".config/katip", configFileName)); string(config) != "shell: zsh\n" {
+		t.Errorf("config in use is replaced with %q", config)
+	}
+
+	// nothing is moved into KATIP_HOME
+	katipHome := t.TempDir()
+	t.Setenv(homeEnvVariable, katipHome)
+	write(filepath.Join(home, legacyAppDirName, commandsFileName), `{"commands": []}`)
+	if err := migrateLegacyDirs(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(home, legacyAppDirName, commandsFileName)); err != nil {
+		t.Error("legacy directory is moved into KATIP_HOME")
+	}
+}
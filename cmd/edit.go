@@ -23,8 +23,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"os/exec"
 
 	"github.com/spf13/cobra"
 )
@@ -51,14 +49,12 @@ var editCmd = &cobra.Command{
 			fmt.Println("error while getting commands file path:", err)
 			return
 		}
-		editor := exec.Command("vim", commandsFilePath)
-		editor.Stdin = os.Stdin
-		editor.Stdout = os.Stdout
-		err = editor.Run()
+		err = openInEditor(commandsFilePath)
 		if err != nil {
-			fmt.Println("error occured while opening vim:", err)
+			fmt.Println("error occured while opening editor:", err)
 			return
 		}
+		commitStoreChangeOrWarn("Edit commands")
 		return
 	},
 }
@@ -0,0 +1,150 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Expands ~ and environment variables in path
+func expandPath(path string) string {
+	return expandHome(os.ExpandEnv(path))
+}
+
+// Parses a dotenv file into variables. Lines are KEY=VALUE, optionally
+// prefixed by export. Single quoted values are taken literally, other values
+// have ${VAR} expanded.
+func readEnvFile(path string, lookup func(string) string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	variables := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		index := strings.Index(line, "=")
+		if index <= 0 {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNumber)
+		}
+		key := strings.TrimSpace(line[:index])
+		value := strings.TrimSpace(line[index+1:])
+		// anything after the closing quote, such as a comment, is ignored
+		end := -1
+		if strings.HasPrefix(value, "'") || strings.HasPrefix(value, `"`) {
+			end = findClosingQuote(value)
+		}
+		switch {
+		case end > 0 && value[0] == '\'':
+			value = value[1:end]
+		case end > 0:
+			value = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value[1:end])
+			value = os.Expand(value, envLookup(variables, lookup))
+		default:
+			if comment := strings.Index(value, " #"); comment >= 0 {
+				value = strings.TrimSpace(value[:comment])
+			}
+			value = os.Expand(value, envLookup(variables, lookup))
+		}
+		variables[key] = value
+	}
+	return variables, scanner.Err()
+}
+
+// Returns index of the quote closing the quoted value, or -1. Double quotes
+// can be escaped with a backslash.
+func findClosingQuote(value string) int {
+	for i := 1; i < len(value); i++ {
+		if value[0] == '"' && value[i] == '\\' {
+			i++
+		} else if value[i] == value[0] {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns a lookup function preferring variables over lookup
+func envLookup(variables map[string]string, lookup func(string) string) func(string) string {
+	return func(name string) string {
+		if value, ok := variables[name]; ok {
+			return value
+		}
+		return lookup(name)
+	}
+}
+
+// Returns environment variables command sets, in KEY=VALUE form sorted by
+// key. Variables of env override the ones in env_file, and both can refer
+// to katip's environment.
+func getCommandEnv(command Command) ([]string, error) {
+	variables := map[string]string{}
+	if command.EnvFile != "" {
+		fileVariables, err := readEnvFile(expandPath(command.EnvFile), os.Getenv)
+		if err != nil {
+			return nil, err
+		}
+		variables = fileVariables
+	}
+	lookup := envLookup(variables, os.Getenv)
+	for _, key := range sortedKeys(command.Env) {
+		variables[key] = expandHome(os.Expand(command.Env[key], lookup))
+	}
+	var env []string
+	for _, key := range sortedKeys(variables) {
+		env = append(env, key+"="+variables[key])
+	}
+	return env, nil
+}
+
+// Parses KEY=VALUE pairs given on the command line
+func parseEnvPairs(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	env := map[string]string{}
+	for _, pair := range pairs {
+		index := strings.Index(pair, "=")
+		if index <= 0 {
+			return nil, fmt.Errorf("invalid environment variable %q, expected KEY=VALUE", pair)
+		}
+		env[pair[:index]] = pair[index+1:]
+	}
+	return env, nil
+}
+
+// Returns variables of env as KEY=VALUE, sorted by key
+func formatEnv(env map[string]string) string {
+	var pairs []string
+	for _, key := range sortedKeys(env) {
+		pairs = append(pairs, key+"="+env[key])
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	lookup := func(name string) string {
+		return map[string]string{"HOME": "/home/me", "USER": "me"}[name]
+	}
+	tests := []struct {
+		content   string
+		variables map[string]string
+		err       string
+	}{
+		{"A=1\nB = two\n", map[string]string{"A": "1", "B": "two"}, ""},
+		{"# comment\n\nexport A=1\n", map[string]string{"A": "1"}, ""},
+		{"A=1 # comment\nB=x#y\n", map[string]string{"A": "1", "B": "x#y"}, ""},
+		{`A='$HOME # kept'`, map[string]string{"A": "$HOME # kept"}, ""},
+		{`A="$HOME/x" # comment`, map[string]string{"A": "/home/me/x"}, ""},
+		{`A="line\none \"quoted\" back\\slash"`, map[string]string{"A": "line\none \"quoted\" back\\slash"}, ""},
+		{"DIR=/srv\nLOGS=${DIR}/logs\nWHO=$USER\n", map[string]string{"DIR": "/srv", "LOGS": "/srv/logs", "WHO": "me"}, ""},
+		{"HOME=/override\nX=$HOME\n", map[string]string{"HOME": "/override", "X": "/override"}, ""},
+		{"A=\n", map[string]string{"A": ""}, ""},
+		{"A=1\nnot a pair\n", nil, ":2: expected KEY=VALUE"},
+		{"=1\n", nil, ":1: expected KEY=VALUE"},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), ".env")
+		if err := ioutil.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		variables, err := readEnvFile(path, lookup)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("readEnvFile(%q) error %v, want %q", test.content, err, test.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(variables, test.variables) {
+			t.Errorf("readEnvFile(%q) = %q (%v), want %q", test.content, variables, err, test.variables)
+		}
+	}
+}
+
+func TestGetCommandEnv(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("KATIP_TEST_REGION", "eu")
+	envFile := filepath.Join(home, ".env")
+	if err := ioutil.WriteFile(envFile, []byte("REGION=$KATIP_TEST_REGION\nLEVEL=info\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		command Command
+		env     []string
+	}{
+		{Command{}, nil},
+		{Command{Env: map[string]string{"B": "2", "A": "1"}}, []string{"A=1", "B=2"}},
+		{Command{Env: map[string]string{"CONFIG": "~/app.yaml"}}, []string{"CONFIG=" + home + "/app.yaml"}},
+		{Command{EnvFile: "~/.env"}, []string{"LEVEL=info", "REGION=eu"}},
+		// env overrides env_file and can refer to its variables
+		{Command{EnvFile: "$HOME/.env", Env: map[string]string{"LEVEL": "debug", "ZONE": "$REGION-1"}}, []string{"LEVEL=debug", "REGION=eu", "ZONE=eu-1"}},
+	}
+	for _, test := range tests {
+		if env, err := getCommandEnv(test.command); err != nil || !reflect.DeepEqual(env, test.env) {
+			t.Errorf("getCommandEnv(%+v) = %q (%v), want %q", test.command, env, err, test.env)
+		}
+	}
+	if _, err := getCommandEnv(Command{EnvFile: "~/missing.env"}); err == nil {
+		t.Error("getCommandEnv() with a missing env file gave no error")
+	}
+}
+
+func TestParseEnvPairs(t *testing.T) {
+	tests := []struct {
+		pairs []string
+		env   map[string]string
+		valid bool
+	}{
+		{nil, nil, true},
+		{[]string{"A=1", "B=x=y", "C="}, map[string]string{"A": "1", "B": "x=y", "C": ""}, true},
+		{[]string{"A"}, nil, false},
+		{[]string{"=1"}, nil, false},
+	}
+	for _, test := range tests {
+		env, err := parseEnvPairs(test.pairs)
+		if (err == nil) != test.valid || !reflect.DeepEqual(env, test.env) {
+			t.Errorf("parseEnvPairs(%q) = %q (%v), want %q", test.pairs, env, err, test.env)
+		}
+	}
+	if formatted := formatEnv(map[string]string{"B": "2", "A": "1"}); formatted != "A=1 B=2" {
+		t.Errorf("formatEnv() = %q", formatted)
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Flags interpreters take the code to run with. Interpreters which are not
+// listed take it with -c, like shells do.
+var interpreterCodeFlags = map[string]string{
+	"node":    "-e",
+	"perl":    "-e",
+	"ruby":    "-e",
+	"pwsh":    "-Command",
+	"deno":    "eval",
+	"php":     "-r",
+	"lua":     "-e",
+	"bun":     "-e",
+	"Rscript": "-e",
+}
+
+// Returns the interpreter command runs with: its own, or the configured
+// shell
+func getCommandInterpreter(command Command) string {
+	if strings.TrimSpace(command.Interpreter) != "" {
+		return command.Interpreter
+	}
+	return viper.GetString("shell")
+}
+
+// Returns arguments which run code with interpreter. A single name, such as
+// zsh or python3, is given the code with its usual flag. A custom argv, such
+// as "ruby -w -e", is given the code as its last argument.
+func getInterpreterArgs(interpreter, code string) []string {
+	fields := strings.Fields(interpreter)
+	if len(fields) == 0 {
+		fields = []string{"sh"}
+	}
+	if len(fields) > 1 {
+		return append(fields, code)
+	}
+	flag, ok := interpreterCodeFlags[filepath.Base(fields[0])]
+	if !ok {
+		flag = "-c"
+	}
+	return []string{fields[0], flag, code}
+}
+
+// Lines which make interpreters stop at the first failing command
+var failFastPreambles = map[string]string{
+	"sh":   "set -e\n",
+	"bash": "set -e\n",
+	"zsh":  "set -e\n",
+	"dash": "set -e\n",
+	"ksh":  "set -e\n",
+	"ash":  "set -e\n",
+	"pwsh": "$ErrorActionPreference = 'Stop'\n",
+}
+
+// Checks if command is a script of more than one line
+func isScript(command Command) bool {
+	return strings.Contains(strings.TrimRight(command.Command, "\n"), "\n")
+}
+
+// Subcommands interpreters run a script file with, for the ones which do not
+// take the file as their first argument
+var interpreterScriptCommands = map[string]string{
+	"deno": "run",
+}
+
+// Returns arguments which run the script file at path with interpreter. A
+// trailing flag of a custom argv, the one which takes code, is dropped.
+func getInterpreterScriptArgs(interpreter, path string) []string {
+	fields := strings.Fields(interpreter)
+	if len(fields) == 0 {
+		fields = []string{"sh"}
+	}
+	name := filepath.Base(fields[0])
+	if last := fields[len(fields)-1]; len(fields) > 1 && (strings.HasPrefix(last, "-") || last == interpreterCodeFlags[name]) {
+		fields = fields[:len(fields)-1]
+	}
+	if subcommand, ok := interpreterScriptCommands[name]; ok && (len(fields) == 1 || fields[1] != subcommand) {
+		fields = append([]string{fields[0], subcommand}, fields[1:]...)
+	}
+	return append(fields, path)
+}
+
+// Argument standing for the script file until it is written
+const scriptFileArg = "<script file>"
+
+// What running a command executes
+type runPlan struct {
+	Args   []string // argv, ending with scriptFileArg for scripts
+	Script string   // code, written to a file for scripts
+	Dir    string
+	Env    []string // variables set in addition to katip's environment
+}
+
+// Returns what running command executes. If failFast is set, shells stop at
+// the first failing line.
+func planRun(command Command, failFast bool) (runPlan, error) {
+	interpreter := getCommandInterpreter(command)
+	code := command.Command
+	if fields := strings.Fields(interpreter); failFast && len(fields) > 0 {
+		code = failFastPreambles[filepath.Base(fields[0])] + code
+	}
+	plan := runPlan{Script: code}
+	plan.Dir, _ = os.Getwd()
+	if command.Workdir != "" {
+		plan.Dir = expandPath(command.Workdir)
+	}
+	var err error
+	if plan.Env, err = getCommandEnv(command); err != nil {
+		return plan, err
+	}
+	if isScript(command) {
+		plan.Args = getInterpreterScriptArgs(interpreter, scriptFileArg)
+	} else {
+		plan.Args = getInterpreterArgs(interpreter, code)
+	}
+	return plan, nil
+}
+
+// Checks if plan runs a script file
+func (plan runPlan) isScript() bool {
+	return plan.Args[len(plan.Args)-1] == scriptFileArg
+}
+
+// Returns the process which runs plan. Scripts are written to a temporary
+// file which is removed by the returned cleanup function.
+func (plan runPlan) command() (*exec.Cmd, func(), error) {
+	args := append([]string(nil), plan.Args...)
+	cleanup := func() {}
+	if plan.isScript() {
+		file, err := ioutil.TempFile("", "katip-script-")
+		if err != nil {
+			return nil, nil, err
+		}
+		cleanup = func() { os.Remove(file.Name()) }
+		code := plan.Script
+		if !strings.HasSuffix(code, "\n") {
+			code += "\n"
+		}
+		_, err = file.WriteString(code)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		args[len(args)-1] = file.Name()
+	}
+	runCommand := exec.Command(args[0], args[1:]...)
+	runCommand.Dir = plan.Dir
+	if len(plan.Env) > 0 {
+		runCommand.Env = append(os.Environ(), plan.Env...)
+	}
+	return runCommand, cleanup, nil
+}
+
+// Returns the process which runs command, and a function which removes the
+// files it needs once it finishes
+func buildRunCommand(command Command, failFast bool) (*exec.Cmd, func(), error) {
+	plan, err := planRun(command, failFast)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plan.command()
+}
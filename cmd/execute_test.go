@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/spf13/viper"
+)
+
+func TestGetInterpreterArgs(t *testing.T) {
+	tests := []struct {
+		interpreter string
+		want        []string
+	}{
+		{"", []string{"sh", "-c", "code"}},
+		{"bash", []string{"bash", "-c", "code"}},
+		{"/bin/zsh", []string{"/bin/zsh", "-c", "code"}},
+		{"python3", []string{"python3", "-c", "code"}},
+		{"node", []string{"node", "-e", "code"}},
+		{"perl", []string{"perl", "-e", "code"}},
+		{"ruby", []string{"ruby", "-e", "code"}},
+		{"pwsh", []string{"pwsh", "-Command", "code"}},
+		{"deno", []string{"deno", "eval", "code"}},
+		{"php", []string{"php", "-r", "code"}},
+		{"lua", []string{"lua", "-e", "code"}},
+		{"bun", []string{"bun", "-e", "code"}},
+		{"Rscript", []string{"Rscript", "-e", "code"}},
+		{"ruby -w -e", []string{"ruby", "-w", "-e", "code"}},
+	}
+	for _, test := range tests {
+		if args := getInterpreterArgs(test.interpreter, "code"); !reflect.DeepEqual(args, test.want) {
+			t.Errorf("getInterpreterArgs(%q) = %q, want %q", test.interpreter, args, test.want)
+		}
+	}
+}
+
+func TestGetInterpreterScriptArgs(t *testing.T) {
+	tests := []struct {
+		interpreter string
+		want        []string
+	}{
+		{"", []string{"sh", "script"}},
+		{"bash", []string{"bash", "script"}},
+		{"/bin/zsh", []string{"/bin/zsh", "script"}},
+		{"python3", []string{"python3", "script"}},
+		{"node", []string{"node", "script"}},
+		{"perl", []string{"perl", "script"}},
+		{"ruby", []string{"ruby", "script"}},
+		{"pwsh", []string{"pwsh", "script"}},
+		{"deno", []string{"deno", "run", "script"}},
+		{"php", []string{"php", "script"}},
+		{"lua", []string{"lua", "script"}},
+		{"bun", []string{"bun", "script"}},
+		{"Rscript", []string{"Rscript", "script"}},
+		{"ruby -w -e", []string{"ruby", "-w", "script"}},
+		{"bash -eu -c", []string{"bash", "-eu", "script"}},
+		{"deno eval", []string{"deno", "run", "script"}},
+		{"deno run", []string{"deno", "run", "script"}},
+	}
+	for _, test := range tests {
+		if args := getInterpreterScriptArgs(test.interpreter, "script"); !reflect.DeepEqual(args, test.want) {
+			t.Errorf("getInterpreterScriptArgs(%q) = %q, want %q", test.interpreter, args, test.want)
+		}
+	}
+}
+
+func TestIsScript(t *testing.T) {
+	tests := []struct {
+		command string
+		script  bool
+	}{
+		{"ls -la", false},
+		{"ls -la\n", false},
+		{"ls -la\n\n", false},
+		{"cd /tmp\nls", true},
+		{"for f in *; do\n  echo $f\ndone\n", true},
+	}
+	for _, test := range tests {
+		if script := isScript(Command{Command: test.command}); script != test.script {
+			t.Errorf("isScript(%q) = %v, want %v", test.command, script, test.script)
+		}
+	}
+}
+
+func TestPlanRun(t *testing.T) {
+	viper.Set("shell", "bash")
+	defer viper.Set("shell", nil)
+
+	tests := []struct {
+		command  Command
+		failFast bool
+		args     []string
+		script   string
+	}{
+		{Command{Command: "ls"}, false, []string{"bash", "-c", "ls"}, "ls"},
+		{Command{Command: "ls"}, true, []string{"bash", "-c", "set -e\nls"}, "set -e\nls"},
+		{Command{Command: "cd /\nls"}, false, []string{"bash", scriptFileArg}, "cd /\nls"},
+		{Command{Command: "cd /\nls"}, true, []string{"bash", scriptFileArg}, "set -e\ncd /\nls"},
+		{Command{Command: "print(1)\nprint(2)", Interpreter: "python3"}, true, []string{"python3", scriptFileArg}, "print(1)\nprint(2)"},
+		{Command{Command: "console.log(1)", Interpreter: "node"}, false, []string{"node", "-e", "console.log(1)"}, "console.log(1)"},
+	}
+	for _, test := range tests {
+		plan, err := planRun(test.command, test.failFast)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(plan.Args, test.args) || plan.Script != test.script {
+			t.Errorf("planRun(%q, %v) = %q %q, want %q %q", test.command.Command, test.failFast, plan.Args, plan.Script, test.args, test.script)
+		}
+		if plan.isScript() != isScript(test.command) {
+			t.Errorf("planRun(%q) runs a script file: %v", test.command.Command, plan.isScript())
+		}
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	runCommand, cleanup, err := buildRunCommand(Command{Command: "echo one\nfalse\necho two", Interpreter: "sh"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := runCommand.Args[len(runCommand.Args)-1]
+	out, err := runCommand.Output()
+	if err == nil || string(out) != "one\n" {
+		t.Errorf("script stopping at the first failure printed %q (%v)", out, err)
+	}
+	cleanup()
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("script file %s is not removed: %v", path, err)
+	}
+}
+
+func TestFormatScriptCell(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = true
+
+	long := strings.Repeat("echo line\n", maxTableScriptLines+2)
+	tests := []struct {
+		script string
+		width  int
+		want   string
+	}{
+		{"ls -la", 0, "ls -la"},
+		{"echo first\necho second\n", 0, "echo first\necho second"},
+		{"echo a long line\nls", 8, "echo ...\nls"},
+		{long, 0, strings.Repeat("echo line\n", maxTableScriptLines-1) + "... 3 more lines"},
+	}
+	for _, test := range tests {
+		if cell := formatScriptCell(test.script, test.width); cell != test.want {
+			t.Errorf("formatScriptCell(%q, %d) = %q, want %q", test.script, test.width, cell, test.want)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	toml "github.com/pelletier/go-toml"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	exportTo     string
+	exportTags   []string
+	exportOutput string
+)
+
+// Title of the markdown cheatsheet section for commands without tags
+var untaggedSectionTitle = "Other"
+
+// Writers of the supported export formats
+var commandExporters = map[string]func(commands []Command) ([]byte, error){
+	"keep":     exportToKeep,
+	"pet":      exportToPet,
+	"navi":     exportToNavi,
+	"markdown": exportToMarkdown,
+	"json":     exportToJSON,
+	"yaml":     exportToYAML,
+}
+
+// Writes commands in keep's commands.json format
+func exportToKeep(commands []Command) ([]byte, error) {
+	type keepCommand struct {
+		Alias string `json:"alias"`
+		Desc  string `json:"desc"`
+	}
+	keepCommands := map[string]keepCommand{}
+	for _, command := range commands {
+		keepCommands[command.Command] = keepCommand{Alias: command.Alias, Desc: command.Description}
+	}
+	return marshalJSON(keepCommands)
+}
+
+// Writes commands in pet's snippet.toml format
+func exportToPet(commands []Command) ([]byte, error) {
+	type petSnippet struct {
+		Description string   `toml:"description"`
+		Command     string   `toml:"command"`
+		Tag         []string `toml:"tag"`
+		Output      string   `toml:"output"`
+	}
+	var snippets struct {
+		Snippets []petSnippet `toml:"snippets"`
+	}
+	for _, command := range commands {
+		tags := command.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		snippets.Snippets = append(snippets.Snippets, petSnippet{
+			Description: command.Description,
+			Command:     command.Command,
+			Tag:         tags,
+		})
+	}
+	return toml.Marshal(snippets)
+}
+
+// Writes commands as a navi cheat file, one "%" section per tag set
+func exportToNavi(commands []Command) ([]byte, error) {
+	var buf bytes.Buffer
+	var sections []string
+	sectionCommands := map[string][]Command{}
+	for _, command := range commands {
+		section := strings.Join(command.Tags, ", ")
+		if section == "" {
+			section = "katip"
+		}
+		if _, ok := sectionCommands[section]; !ok {
+			sections = append(sections, section)
+		}
+		sectionCommands[section] = append(sectionCommands[section], command)
+	}
+	for i, section := range sections {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "%% %s\n", section)
+		variables := map[string]string{}
+		for _, command := range sectionCommands[section] {
+			description := command.Description
+			if description == "" {
+				description = command.Alias
+			}
+			fmt.Fprintf(&buf, "\n# %s\n%s\n", description, command.Command)
+			for name, value := range command.Variables {
+				variables[name] = value
+			}
+		}
+		if len(variables) > 0 {
+			buf.WriteString("\n")
+			for _, name := range sortedKeys(variables) {
+				fmt.Fprintf(&buf, "$ %s: %s\n", name, variables[name])
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Writes commands as a markdown cheatsheet grouped by tag. Commands with
+// several tags appear in each of their sections.
+func exportToMarkdown(commands []Command) ([]byte, error) {
+	sectionCommands := map[string][]Command{}
+	for _, command := range commands {
+		if len(command.Tags) == 0 {
+			sectionCommands[untaggedSectionTitle] = append(sectionCommands[untaggedSectionTitle], command)
+			continue
+		}
+		for _, tag := range command.Tags {
+			sectionCommands[tag] = append(sectionCommands[tag], command)
+		}
+	}
+	var sections []string
+	for section := range sectionCommands {
+		if section != untaggedSectionTitle {
+			sections = append(sections, section)
+		}
+	}
+	sort.Strings(sections)
+	if _, ok := sectionCommands[untaggedSectionTitle]; ok {
+		sections = append(sections, untaggedSectionTitle)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Cheatsheet\n")
+	for _, section := range sections {
+		fmt.Fprintf(&buf, "\n## %s\n", section)
+		for _, command := range sectionCommands[section] {
+			title := command.Description
+			if title == "" {
+				title = command.Alias
+			}
+			if command.Alias != "" && title != command.Alias {
+				title += " (`" + command.Alias + "`)"
+			}
+			fence := "```"
+			for strings.Contains(command.Command, fence) {
+				fence += "`"
+			}
+			fmt.Fprintf(&buf, "\n- %s\n\n  %ssh\n", title, fence)
+			for _, line := range strings.Split(command.Command, "\n") {
+				fmt.Fprintf(&buf, "  %s\n", line)
+			}
+			fmt.Fprintf(&buf, "  %s\n", fence)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Writes commands in katip's own commands file format
+func exportToJSON(commands []Command) ([]byte, error) {
+	return marshalJSON(Commands{Commands: commands})
+}
+
+// Writes commands as YAML
+func exportToYAML(commands []Command) ([]byte, error) {
+	return yaml.Marshal(Commands{Commands: commands})
+}
+
+// Marshals v as indented JSON without escaping <, > and & which are common
+// in shell commands
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Returns keys of m in sorted order
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Formats which can hold only plain commands, not workflows or runbooks
+var plainCommandFormats = []string{"keep", "pet", "navi", "markdown"}
+
+// Returns commands which format can hold and the number of those skipped.
+// Workflows and runbooks are only kept in katip's own formats.
+func filterExportableCommands(commands []Command, format string) ([]Command, int) {
+	if !isStringInSlice(format, plainCommandFormats) {
+		return commands, 0
+	}
+	var exportable []Command
+	for _, command := range commands {
+		if isWorkflow(command) || command.Runbook != "" {
+			continue
+		}
+		exportable = append(exportable, command)
+	}
+	return exportable, len(commands) - len(exportable)
+}
+
+// Returns commands of the library in use, or of every library with
+// --all-libraries. Project and system commands are not exported, they are
+// not saved by the user.
+func getExportedCommands() ([]Command, error) {
+	libraries, err := getSearchedLibraries()
+	if err != nil {
+		return nil, err
+	}
+	var commands []Command
+	for _, library := range libraries {
+		libraryCommands, err := readLibraryCommands(library)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, libraryCommands.Commands...)
+	}
+	return commands, nil
+}
+
+// Returns commands which have at least one of tags. All commands are
+// returned if tags is empty.
+func filterCommandsByTags(commands []Command, tags []string) []Command {
+	if len(tags) == 0 {
+		return commands
+	}
+	var filtered []Command
+	for _, command := range commands {
+		for _, tag := range command.Tags {
+			if isStringInSlice(tag, tags) {
+				filtered = append(filtered, command)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export --to keep|pet|navi|markdown|json|yaml",
+	Short: "Exports your saved commands to other formats",
+	Long: `Exports the commands of the library in use, or of every library with
+--all-libraries. Commands of project and system files are not exported.
+Workflows and runbooks are exported only to json and yaml, other formats
+can not hold them.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// check if app directory exists
+		isAppDirExists, err := checkIfAppDirExists()
+		if err != nil || isAppDirExists == false {
+			// if app directory does not exist, call init command
+			initCmd.Run(cmd, args)
+			return
+		}
+
+		exporter, ok := commandExporters[exportTo]
+		if !ok {
+			fmt.Println("Please specify format with --to keep, pet, navi, markdown, json or yaml")
+			return
+		}
+		commands, err := getExportedCommands()
+		if err != nil {
+			fmt.Println("get commands error:", err)
+			return
+		}
+		selected, skipped := filterExportableCommands(filterCommandsByTags(commands, exportTags), exportTo)
+		if skipped > 0 {
+			fmt.Fprintf(os.Stderr, "%d workflow(s) and runbook(s) are skipped, %s can not hold them\n", skipped, exportTo)
+		}
+		if len(selected) == 0 {
+			fmt.Println("No commands to export")
+			return
+		}
+		out, err := exporter(selected)
+		if err != nil {
+			fmt.Println("export error:", err)
+			return
+		}
+		if len(out) > 0 && out[len(out)-1] != '\n' {
+			out = append(out, '\n')
+		}
+		if exportOutput == "" || exportOutput == "-" {
+			os.Stdout.Write(out)
+			return
+		}
+		err = ioutil.WriteFile(exportOutput, out, 0644)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "%d command(s) exported to %s\n", len(selected), exportOutput)
+		return
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVar(&exportTo, "to", "", "target format: keep, pet, navi, markdown, json or yaml")
+	exportCmd.Flags().StringSliceVar(&exportTags, "tag", nil, "export only commands with one of these tags")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "write to file instead of stdout")
+}
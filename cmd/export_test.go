@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var exportedCommands = []Command{
+	{Command: "kubectl get pods -n <namespace>", Description: "pods", Alias: "pods", Tags: []string{"k8s"},
+		Variables: map[string]string{"namespace": "kubectl get ns"}},
+	{Command: "git log --oneline | head -n 5", Description: "recent commits", Tags: []string{"git"}},
+	{Command: "df -h", Alias: "disk"},
+}
+
+// Returns the fields every format keeps, sorted by command
+func exportedFields(commands []Command, withAlias, withTags bool) [][3]string {
+	var fields [][3]string
+	for _, command := range commands {
+		field := [3]string{command.Command, command.Description}
+		if withAlias {
+			field[2] = command.Alias
+		}
+		if withTags && len(command.Tags) > 0 {
+			field[2] += "#" + command.Tags[0]
+		}
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i][0] < fields[j][0] })
+	return fields
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		format    string
+		file      string
+		read      func(path string) ([]Command, error)
+		withAlias bool
+		withTags  bool
+	}{
+		{"keep", "commands.json", importFromKeep, true, false},
+		{"pet", "snippet.toml", importFromPet, false, true},
+		{"navi", "katip.cheat", importFromNavi, false, true},
+	}
+	for _, test := range tests {
+		content, err := commandExporters[test.format](exportedCommands)
+		if err != nil {
+			t.Fatalf("%s: %v", test.format, err)
+		}
+		path := filepath.Join(dir, test.file)
+		if err = ioutil.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		imported, err := test.read(path)
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", test.format, err, content)
+		}
+		want := exportedCommands
+		if test.format == "navi" {
+			// navi needs a description, the alias stands in for it
+			want = append([]Command(nil), exportedCommands...)
+			want[2].Description = want[2].Alias
+			want[2].Tags = []string{"katip"}
+		}
+		if got, want := exportedFields(imported, test.withAlias, test.withTags), exportedFields(want, test.withAlias, test.withTags); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s round trip:\n got %q\nwant %q\n%s", test.format, got, want, content)
+		}
+		if test.format == "navi" && imported[0].Variables["namespace"] != "kubectl get ns" {
+			t.Errorf("navi variables are lost: %v", imported[0].Variables)
+		}
+	}
+}
+
+func TestExportToMarkdown(t *testing.T) {
+	content, err := exportToMarkdown(append(exportedCommands, Command{Command: "echo ```", Description: "fence"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Cheatsheet\n" +
+		"\n## git\n\n- recent commits\n\n  ```sh\n  git log --oneline | head -n 5\n  ```\n" +
+		"\n## k8s\n\n- pods\n\n  ```sh\n  kubectl get pods -n <namespace>\n  ```\n" +
+		"\n## Other\n\n- disk\n\n  ```sh\n  df -h\n  ```\n" +
+		"\n- fence\n\n  ````sh\n  echo ```\n  ````\n"
+	if string(content) != want {
+		t.Errorf("markdown:\n%s\nwant:\n%s", content, want)
+	}
+}
+
+func TestExportOwnFormats(t *testing.T) {
+	for _, format := range []string{"json", "yaml"} {
+		content, err := commandExporters[format](exportedCommands)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var commands Commands
+		if format == "json" {
+			err = json.Unmarshal(content, &commands)
+		} else {
+			err = yaml.Unmarshal(content, &commands)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !reflect.DeepEqual(commands.Commands, exportedCommands) {
+			t.Errorf("%s round trip:\n got %+v\nwant %+v", format, commands.Commands, exportedCommands)
+		}
+	}
+}
+
+func TestFilterExportedCommands(t *testing.T) {
+	commands := append([]Command{
+		{ID: "w", Steps: []WorkflowStep{{Command: "pods"}}},
+		{ID: "r", Runbook: "# Restart\n\n```sh\nsystemctl restart app\n```\n"},
+	}, exportedCommands...)
+	for format, skipped := range map[string]int{"keep": 2, "pet": 2, "navi": 2, "markdown": 2, "json": 0, "yaml": 0} {
+		exportable, n := filterExportableCommands(commands, format)
+		if n != skipped || len(exportable) != len(commands)-skipped {
+			t.Errorf("%s: %d exportable, %d skipped", format, len(exportable), n)
+		}
+	}
+
+	tagged := filterCommandsByTags(exportedCommands, []string{"git", "k8s"})
+	if len(tagged) != 2 {
+		t.Errorf("%d commands tagged git or k8s", len(tagged))
+	}
+	if all := filterCommandsByTags(exportedCommands, nil); len(all) != len(exportedCommands) {
+		t.Errorf("no tags filter out commands")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/fatih/color"
+	"github.com/spf13/viper"
+)
+
+// Colors that can be used with the color template function
+var templateColors = map[string]color.Attribute{
+	"black":   color.FgBlack,
+	"red":     color.FgRed,
+	"green":   color.FgGreen,
+	"yellow":  color.FgYellow,
+	"blue":    color.FgBlue,
+	"magenta": color.FgMagenta,
+	"cyan":    color.FgCyan,
+	"white":   color.FgWhite,
+	"bold":    color.Bold,
+}
+
+// Helper functions available to --format templates
+var templateFuncs = template.FuncMap{
+	"truncate": func(length int, s string) string {
+		return truncateString(s, length)
+	},
+	"join": func(sep string, items []string) string {
+		return strings.Join(items, sep)
+	},
+	"color": func(name string, s string) (string, error) {
+		attribute, ok := templateColors[name]
+		if !ok {
+			return "", fmt.Errorf("unknown color %q", name)
+		}
+		return color.New(attribute).Sprint(s), nil
+	},
+}
+
+// Returns the template text for format. If format is the name of a template
+// defined under "templates" in the config file, that template is used.
+func resolveFormat(format string) string {
+	if named := viper.GetStringMapString("templates"); named != nil {
+		if text, ok := named[strings.ToLower(format)]; ok {
+			format = text
+		}
+	}
+	// allow escape sequences to be written literally on the command line
+	return strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+}
+
+// Renders each command with the given Go template, one command per line
+func printCommandsWithTemplate(w io.Writer, commands []Command, format string) error {
+	tmpl, err := template.New("format").Funcs(templateFuncs).Parse(resolveFormat(format))
+	if err != nil {
+		return err
+	}
+	for _, command := range commands {
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, command); err != nil {
+			return err
+		}
+		line := sb.String()
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/spf13/viper"
+)
+
+func TestPrintCommandsWithTemplate(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = false
+	viper.Set("templates", map[string]string{"short": "{{.Alias}}: {{.Description}}"})
+	defer viper.Set("templates", nil)
+
+	commands := []Command{
+		{Command: "git log --oneline --graph", Description: "history", Alias: "lg", Tags: []string{"git", "log"}},
+		{Command: "ls", Description: "files", Alias: "l"},
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"{{.Alias}}", "lg\nl\n"},
+		{`{{.Alias}}\t{{.Command}}`, "lg\tgit log --oneline --graph\nl\tls\n"},
+		{"{{truncate 10 .Command}}", "git log...\nls\n"},
+		{`{{join "," .Tags}}`, "git,log\n\n"},
+		{"SHORT", "lg: history\nl: files\n"},
+		{`{{color "red" .Alias}}`, "\x1b[31mlg\x1b[0m\n\x1b[31ml\x1b[0m\n"},
+	}
+	for _, test := range tests {
+		var out strings.Builder
+		if err := printCommandsWithTemplate(&out, commands, test.format); err != nil {
+			t.Errorf("%q: %v", test.format, err)
+			continue
+		}
+		if out.String() != test.want {
+			t.Errorf("%q printed %q, want %q", test.format, out.String(), test.want)
+		}
+	}
+
+	for _, format := range []string{"{{.Alias", "{{.Unknown}}", `{{color "pink" .Alias}}`} {
+		if err := printCommandsWithTemplate(&strings.Builder{}, commands, format); err == nil {
+			t.Errorf("%q gave no error", format)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	defaultGistAPIURL   = "https://api.github.com"
+	defaultGistTokenEnv = "GITHUB_TOKEN"
+	gistDescription     = "katip commands"
+)
+
+// Gist holding the commands file, accessed through GitHub REST API
+type gistRemote struct {
+	baseURL string
+	token   string
+	id      string
+	client  *http.Client
+}
+
+// Creates gist remote from sync.gist settings. The token is never stored in
+// the config file, only the name of the environment variable holding it.
+func newGistRemote() *gistRemote {
+	return &gistRemote{
+		baseURL: strings.TrimRight(viper.GetString("sync.gist.url"), "/"),
+		token:   os.Getenv(viper.GetString("sync.gist.token_env")),
+		id:      viper.GetString("sync.gist.id"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (g *gistRemote) Name() string {
+	return "gist"
+}
+
+type gistFile struct {
+	Content   string `json:"content"`
+	Truncated bool   `json:"truncated,omitempty"`
+	RawURL    string `json:"raw_url,omitempty"`
+}
+
+type gist struct {
+	ID          string              `json:"id,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Public      bool                `json:"public"`
+	Files       map[string]gistFile `json:"files"`
+}
+
+// Sends a request to GitHub API and decodes the response into out. The
+// response is returned for its headers. A 304 response is not decoded and a
+// 412 response means the gist changed.
+func (g *gistRemote) do(method, url string, header http.Header, body interface{}, out interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if g.token != "" {
+		req.Header.Set("Authorization", "token "+g.token)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return resp, nil
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return nil, errRemoteChanged
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		var apiError struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(respBody, &apiError)
+		return nil, fmt.Errorf("%s %s: %s %s", method, url, resp.Status, apiError.Message)
+	}
+	if out == nil {
+		return resp, nil
+	}
+	return resp, json.Unmarshal(respBody, out)
+}
+
+// Returns commands stored in the gist, or nil if no gist is created yet.
+// The ETag of the gist is returned as its version.
+func (g *gistRemote) Fetch() (*Commands, string, error) {
+	if g.id == "" {
+		return nil, "", nil
+	}
+	var remoteGist gist
+	resp, err := g.do("GET", g.baseURL+"/gists/"+g.id, nil, nil, &remoteGist)
+	if err != nil {
+		return nil, "", err
+	}
+	version := resp.Header.Get("ETag")
+	file, ok := remoteGist.Files[getSyncFileName()]
+	if !ok {
+		return nil, version, nil
+	}
+	content := file.Content
+	if file.Truncated && file.RawURL != "" {
+		// large files are not included in API response
+		if content, err = g.fetchRaw(file.RawURL); err != nil {
+			return nil, "", err
+		}
+	}
+	var commands Commands
+	if strings.TrimSpace(content) == "" {
+		return &commands, version, nil
+	}
+	if err = json.Unmarshal([]byte(content), &commands); err != nil {
+		return nil, "", fmt.Errorf("invalid commands file in gist: %v", err)
+	}
+	return &commands, version, nil
+}
+
+// Returns content of a gist file too large to be included in API responses
+func (g *gistRemote) fetchRaw(rawURL string) (string, error) {
+	resp, err := g.client.Get(rawURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// Writes commands to the gist, creating a secret gist if there is none
+func (g *gistRemote) Store(commands *Commands, version string) error {
+	if g.token == "" {
+		return fmt.Errorf("GitHub token is required, set %s", viper.GetString("sync.gist.token_env"))
+	}
+	content, err := json.MarshalIndent(commands, "", "  ")
+	if err != nil {
+		return err
+	}
+	payload := gist{
+		Description: gistDescription,
+		Files:       map[string]gistFile{getSyncFileName(): {Content: string(content)}},
+	}
+	if g.id != "" {
+		header := http.Header{}
+		if version != "" {
+			// gist API ignores If-Match on updates, so the gist is checked
+			// for changes right before updating it
+			resp, err := g.do("GET", g.baseURL+"/gists/"+g.id, http.Header{"If-None-Match": {version}}, nil, nil)
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode != http.StatusNotModified {
+				return errRemoteChanged
+			}
+			header.Set("If-Match", version)
+		}
+		_, err = g.do("PATCH", g.baseURL+"/gists/"+g.id, header, payload, nil)
+		return err
+	}
+	var created gist
+	if _, err = g.do("POST", g.baseURL+"/gists", nil, payload, &created); err != nil {
+		return err
+	}
+	g.id = created.ID
+	if err = saveConfigValue("sync.gist.id", g.id); err != nil {
+		return fmt.Errorf("gist %s is created but could not be saved to config: %v", g.id, err)
+	}
+	fmt.Println("Created gist", g.id)
+	return nil
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// In-memory GitHub gist API serving a single gist
+type fakeGistAPI struct {
+	mu        sync.Mutex
+	id        string
+	content   string
+	revision  int
+	truncated bool
+	rawStatus int
+	patches   int
+}
+
+func (f *fakeGistAPI) etag() string {
+	return fmt.Sprintf(`W/"%d"`, f.revision)
+}
+
+func (f *fakeGistAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fileName := getSyncFileName()
+	switch {
+	case r.URL.Path == "/raw":
+		if f.rawStatus != 0 {
+			http.Error(w, "rate limited", f.rawStatus)
+			return
+		}
+		fmt.Fprint(w, f.content)
+	case r.Method == "POST" && r.URL.Path == "/gists":
+		var created gist
+		json.NewDecoder(r.Body).Decode(&created)
+		f.id, f.content, f.revision = "g1", created.Files[fileName].Content, 1
+		json.NewEncoder(w).Encode(gist{ID: f.id})
+	case r.URL.Path != "/gists/"+f.id || f.id == "":
+		http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+	case r.Method == "GET":
+		if r.Header.Get("If-None-Match") == f.etag() {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		file := gistFile{Content: f.content}
+		if f.truncated {
+			file = gistFile{Truncated: true, RawURL: "http://" + r.Host + "/raw"}
+		}
+		w.Header().Set("ETag", f.etag())
+		json.NewEncoder(w).Encode(gist{ID: f.id, Files: map[string]gistFile{fileName: file}})
+	case r.Method == "PATCH":
+		if match := r.Header.Get("If-Match"); match != "" && match != f.etag() {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		var updated gist
+		json.NewDecoder(r.Body).Decode(&updated)
+		f.content = updated.Files[fileName].Content
+		f.revision++
+		f.patches++
+		w.Header().Set("ETag", f.etag())
+		json.NewEncoder(w).Encode(gist{ID: f.id})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Returns a gist remote talking to a fake API, with katip using a fresh home
+func newFakeGistRemote(t *testing.T) (*gistRemote, *fakeGistAPI) {
+	t.Setenv(homeEnvVariable, t.TempDir())
+	api := &fakeGistAPI{}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { viper.Set("sync.gist.id", "") })
+	return &gistRemote{baseURL: server.URL, token: "token", client: server.Client()}, api
+}
+
+func TestGistCreateFetchUpdate(t *testing.T) {
+	remote, api := newFakeGistRemote(t)
+	commands, version, err := remote.Fetch()
+	if err != nil || commands != nil || version != "" {
+		t.Fatalf("Fetch without gist = %v, %q, %v", commands, version, err)
+	}
+	if err = remote.Store(&Commands{Commands: []Command{{ID: "a", Command: "echo a"}}}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if remote.id != "g1" || viper.GetString("sync.gist.id") != "g1" {
+		t.Fatalf("created gist ID is not kept, got %q", remote.id)
+	}
+
+	commands, version, err = remote.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commands.Commands) != 1 || commands.Commands[0].Command != "echo a" || version != api.etag() {
+		t.Fatalf("Fetch = %+v, %q", commands, version)
+	}
+	commands.Commands = append(commands.Commands, Command{ID: "b", Command: "echo b"})
+	if err = remote.Store(commands, version); err != nil {
+		t.Fatal(err)
+	}
+	if api.patches != 1 || !strings.Contains(api.content, "echo b") {
+		t.Fatalf("gist is not updated: %s", api.content)
+	}
+}
+
+func TestGistStoreDetectsChanges(t *testing.T) {
+	remote, api := newFakeGistRemote(t)
+	remote.id, api.id, api.revision = "g1", "g1", 1
+	_, version, err := remote.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// someone else updates the gist after it is fetched
+	api.revision++
+	if err = remote.Store(&Commands{}, version); err != errRemoteChanged {
+		t.Fatalf("Store of a changed gist = %v, want errRemoteChanged", err)
+	}
+	if api.patches != 0 {
+		t.Fatal("changed gist is overwritten")
+	}
+}
+
+func TestGistPreconditionFailed(t *testing.T) {
+	remote, api := newFakeGistRemote(t)
+	remote.id, api.id = "g1", "g1"
+	if _, err := remote.do("PATCH", remote.baseURL+"/gists/g1", http.Header{"If-Match": {`W/"stale"`}}, gist{}, nil); err != errRemoteChanged {
+		t.Fatalf("412 response = %v, want errRemoteChanged", err)
+	}
+}
+
+func TestGistFetchRawFile(t *testing.T) {
+	remote, api := newFakeGistRemote(t)
+	remote.id, api.id, api.truncated = "g1", "g1", true
+	api.content = `{"commands":[{"id":"a","command":"echo large"}]}`
+	commands, _, err := remote.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commands.Commands) != 1 || commands.Commands[0].Command != "echo large" {
+		t.Fatalf("raw file is not read: %+v", commands)
+	}
+
+	api.rawStatus = http.StatusForbidden
+	if _, _, err = remote.Fetch(); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Fetch of a failing raw URL = %v, want the status", err)
+	}
+}
+
+func TestGistAPIError(t *testing.T) {
+	remote, _ := newFakeGistRemote(t)
+	remote.id = "missing"
+	_, _, err := remote.Fetch()
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Fetch of a missing gist = %v, want 404", err)
+	}
+}
@@ -0,0 +1,391 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	gitStoreBackend       = "git"
+	defaultGitStoreBranch = "main"
+	gitStoreRemoteName    = "origin"
+)
+
+// Checks if app directory is configured to be a git repository
+func isGitStore() bool {
+	return viper.GetString("store.backend") == gitStoreBackend
+}
+
+// Runs git in app directory and returns its trimmed output
+func runGit(args ...string) (string, error) {
+	appDirPath, err := getAppDirPath()
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	gitCmd := exec.Command("git", args...)
+	gitCmd.Dir = appDirPath
+	gitCmd.Stdout = &out
+	gitCmd.Stderr = &out
+	err = gitCmd.Run()
+	output := strings.TrimSpace(out.String())
+	if err != nil {
+		return output, fmt.Errorf("git %s: %v: %s", args[0], err, output)
+	}
+	return output, nil
+}
+
+// Initializes git repository in app directory if it is not one yet
+func initGitStore() error {
+	if _, err := runGit("rev-parse", "--git-dir"); err == nil {
+		return nil
+	}
+	branch := viper.GetString("store.git.branch")
+	if branch == "" {
+		branch = defaultGitStoreBranch
+	}
+	if _, err := runGit("init"); err != nil {
+		return err
+	}
+	// set branch name of the unborn branch, older git versions have no
+	// init --initial-branch
+	_, err := runGit("symbolic-ref", "HEAD", "refs/heads/"+branch)
+	return err
+}
+
+// Returns git arguments setting a committer identity if user has none
+func gitIdentityArgs() []string {
+	if name, _ := runGit("config", "user.name"); name != "" {
+		if email, _ := runGit("config", "user.email"); email != "" {
+			return nil
+		}
+	}
+	return []string{"-c", "user.name=katip", "-c", "user.email=katip@localhost"}
+}
+
+// Checks if path, relative to app directory in slash form, is a commands
+// file. These are the only files kept in the git store.
+func isStoreFile(file string) bool {
+	if file == commandsFileName {
+		return true
+	}
+	return path.Dir(file) == librariesDirName && path.Ext(file) == ".json"
+}
+
+// Returns library of the commands file at path relative to app directory
+func getLibraryOfStoreFile(file string) string {
+	if file == commandsFileName {
+		return defaultLibrary
+	}
+	return strings.TrimSuffix(path.Base(file), ".json")
+}
+
+// Returns commands files which exist in app directory or are tracked by git,
+// so that deleted libraries are committed too
+func getStoreFiles() ([]string, error) {
+	var files []string
+	libraries, err := getLibraryNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, library := range libraries {
+		if libraryExists(library) {
+			files = append(files, filepath.ToSlash(getLibraryFileName(library)))
+		}
+	}
+	tracked, err := runGit("ls-files")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range strings.Split(tracked, "\n") {
+		if isStoreFile(file) && !isStringInSlice(file, files) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// Commits changes of commands files with message. Nothing is done if store
+// is not a git repository or there is nothing to commit.
+func commitStoreChange(message string) error {
+	if !isGitStore() {
+		return nil
+	}
+	if err := initGitStore(); err != nil {
+		return err
+	}
+	files, err := getStoreFiles()
+	if err != nil || len(files) == 0 {
+		return err
+	}
+	if _, err = runGit(append([]string{"add", "-A", "--"}, files...)...); err != nil {
+		return err
+	}
+	staged, err := runGit("diff", "--cached", "--name-only")
+	if err != nil || staged == "" {
+		return err
+	}
+	args := append(gitIdentityArgs(), "commit", "-q", "-m", message)
+	_, err = runGit(args...)
+	return err
+}
+
+// Commits store change and reports failures without aborting the command
+func commitStoreChangeOrWarn(message string) {
+	if err := commitStoreChange(message); err != nil {
+		fmt.Println("error while committing change:", err)
+	}
+}
+
+// Returns short description of command for commit messages
+func describeCommand(command Command) string {
+	if command.Alias != "" {
+		return command.Alias
+	}
+	return truncateString(strings.Replace(getCommandText(command), "\n", " ", -1), 50)
+}
+
+// Returns commands in commands file at git revision. The file is given
+// relative to app directory in slash form.
+func getCommandsAtRevision(revision, file string) ([]Command, error) {
+	content, err := runGit("show", revision+":"+file)
+	if err != nil {
+		// file did not exist at that revision
+		return nil, nil
+	}
+	if content == "" {
+		return nil, nil
+	}
+	var commands Commands
+	if err = json.Unmarshal([]byte(content), &commands); err != nil {
+		return nil, fmt.Errorf("invalid %s at %s: %v", file, revision, err)
+	}
+	return commands.Commands, nil
+}
+
+// Returns commands files changed between revisions from and to
+func getChangedStoreFiles(from, to string) ([]string, error) {
+	output, err := runGit("diff", "--name-only", from, to)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, file := range strings.Split(output, "\n") {
+		if isStoreFile(file) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// Checks that every commands file at revision is valid JSON
+func validateStoreFiles(revision string) error {
+	output, err := runGit("ls-tree", "-r", "--name-only", revision)
+	if err != nil {
+		return err
+	}
+	for _, file := range strings.Split(output, "\n") {
+		if !isStoreFile(file) {
+			continue
+		}
+		if _, err = getCommandsAtRevision(revision, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fetches the configured remote, rebases local commits onto it and pushes.
+// If rebase fails, commands are merged one by one and conflicts are reported
+// per command.
+func syncGitStore() error {
+	remoteURL := viper.GetString("store.git.remote")
+	if remoteURL == "" {
+		return fmt.Errorf("no remote configured, set store.git.remote")
+	}
+	branch := viper.GetString("store.git.branch")
+	if branch == "" {
+		branch = defaultGitStoreBranch
+	}
+	libraries, err := getLibraryNames()
+	if err != nil {
+		return err
+	}
+	if err = checkConflictsResolved(libraries); err != nil {
+		return err
+	}
+	if err := commitStoreChange("Update commands"); err != nil {
+		return err
+	}
+	if currentURL, err := runGit("remote", "get-url", gitStoreRemoteName); err != nil {
+		if _, err = runGit("remote", "add", gitStoreRemoteName, remoteURL); err != nil {
+			return err
+		}
+	} else if currentURL != remoteURL {
+		if _, err = runGit("remote", "set-url", gitStoreRemoteName, remoteURL); err != nil {
+			return err
+		}
+	}
+	if _, err := runGit("fetch", "-q", gitStoreRemoteName); err != nil {
+		return err
+	}
+
+	remoteRef := gitStoreRemoteName + "/" + branch
+	_, err = runGit("rev-parse", "--verify", "-q", remoteRef)
+	remoteBranchExists := err == nil
+	_, err = runGit("rev-parse", "--verify", "-q", "HEAD")
+	localBranchExists := err == nil
+	switch {
+	case remoteBranchExists && !localBranchExists:
+		// nothing saved locally yet, start from remote
+		if _, err := runGit("reset", "-q", "--hard", remoteRef); err != nil {
+			return err
+		}
+	case remoteBranchExists:
+		if err := rebaseGitStore(remoteRef); err != nil {
+			return err
+		}
+	}
+	if !localBranchExists && !remoteBranchExists {
+		fmt.Println(warningCommandsFileNotExist)
+		return nil
+	}
+	if _, err := runGit("push", "-q", gitStoreRemoteName, "HEAD:refs/heads/"+branch); err != nil {
+		return err
+	}
+	fmt.Println("Commands are synced with", remoteURL)
+	return nil
+}
+
+// Rebases local commits onto remoteRef. When git can not rebase the commands
+// files cleanly, the rebase is undone and remote is merged instead. Commands
+// files changed on both sides are merged command by command, and conflicts
+// are reported per command. Other changes are merged by git.
+func rebaseGitStore(remoteRef string) error {
+	_, rebaseErr := runGit(append(gitIdentityArgs(), "rebase", "-q", remoteRef)...)
+	if rebaseErr == nil {
+		// textual merge of JSON may still produce an invalid file
+		if err := validateStoreFiles("HEAD"); err == nil {
+			return nil
+		}
+		if _, err := runGit("reset", "-q", "--hard", "ORIG_HEAD"); err != nil {
+			return err
+		}
+	} else if _, err := runGit("rebase", "--abort"); err != nil {
+		return err
+	}
+
+	mergeBase, err := runGit("merge-base", "HEAD", remoteRef)
+	if err != nil {
+		return err
+	}
+	localFiles, err := getChangedStoreFiles(mergeBase, "HEAD")
+	if err != nil {
+		return err
+	}
+	remoteFiles, err := getChangedStoreFiles(mergeBase, remoteRef)
+	if err != nil {
+		return err
+	}
+	// a failing merge leaves conflicting files to be merged below
+	_, mergeErr := runGit(append(gitIdentityArgs(), "merge", "-q", "--no-commit", "--no-ff", remoteRef)...)
+	if _, err = runGit("rev-parse", "-q", "--verify", "MERGE_HEAD"); err != nil {
+		if mergeErr != nil {
+			return mergeErr
+		}
+		return fmt.Errorf("git merge of %s did not start", remoteRef)
+	}
+	conflictCount := 0
+	for _, file := range localFiles {
+		if !isStringInSlice(file, remoteFiles) {
+			continue
+		}
+		count, err := mergeStoreFile(file, mergeBase, remoteRef)
+		if err != nil {
+			runGit("merge", "--abort")
+			return err
+		}
+		conflictCount += count
+	}
+	if unmerged, err := runGit("diff", "--name-only", "--diff-filter=U"); err != nil || unmerged != "" {
+		runGit("merge", "--abort")
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("can not merge %s with %s", strings.Replace(unmerged, "\n", ", ", -1), remoteRef)
+	}
+	message := fmt.Sprintf("Merge %s", remoteRef)
+	if conflictCount > 0 {
+		message += fmt.Sprintf(" (%d conflicting command(s))", conflictCount)
+	}
+	_, err = runGit(append(gitIdentityArgs(), "commit", "-q", "-m", message)...)
+	return err
+}
+
+// Merges commands of a commands file changed both locally and on remoteRef
+// since mergeBase, writes the result and stages it. Returns the number of
+// conflicts.
+func mergeStoreFile(file, mergeBase, remoteRef string) (int, error) {
+	base, err := getCommandsAtRevision(mergeBase, file)
+	if err != nil {
+		return 0, err
+	}
+	local, err := getCommandsAtRevision("HEAD", file)
+	if err != nil {
+		return 0, err
+	}
+	remote, err := getCommandsAtRevision(remoteRef, file)
+	if err != nil {
+		return 0, err
+	}
+	alignCommandIDs(remote, local)
+	library := getLibraryOfStoreFile(file)
+	merged, conflicts := mergeCommands(base, local, remote)
+	merged, err = resolveConflicts(merged, conflicts, conflictMode, library)
+	if err != nil {
+		return 0, err
+	}
+	commandsFilePath, err := getLibraryCommandsFilePath(library)
+	if err != nil {
+		return 0, err
+	}
+	if err = os.MkdirAll(filepath.Dir(commandsFilePath), 0755); err != nil {
+		return 0, err
+	}
+	if err = writeLibraryCommands(library, Commands{Commands: merged}); err != nil {
+		return 0, err
+	}
+	if _, err = runGit("add", "--", file); err != nil {
+		return 0, err
+	}
+	return len(conflicts), nil
+}
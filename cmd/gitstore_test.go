@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// Points katip at a fresh home directory syncing with the git store remote
+func useGitStoreHome(t *testing.T, home, remote string) {
+	t.Setenv(homeEnvVariable, home)
+	viper.Set("store.backend", gitStoreBackend)
+	viper.Set("store.git.remote", remote)
+	viper.Set("store.git.branch", defaultGitStoreBranch)
+	appDirPath, err := getAppDirPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(appDirPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Saves commands to library and commits them
+func saveStoreCommands(t *testing.T, library string, commands ...Command) {
+	path, err := getLibraryCommandsFilePath(library)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeLibraryCommands(library, Commands{Commands: commands}); err != nil {
+		t.Fatal(err)
+	}
+	if err = commitStoreChange("Save commands"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readStoreCommands(t *testing.T, library string) map[string]Command {
+	path, err := getLibraryCommandsFilePath(library)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands, err := readCommandsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := map[string]Command{}
+	for _, command := range commands.Commands {
+		byID[command.ID] = command
+	}
+	return byID
+}
+
+func newGitStoreRemote(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	if out, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	t.Cleanup(func() {
+		viper.Set("store.backend", "")
+		viper.Set("store.git.remote", "")
+	})
+	return remote
+}
+
+func TestGitStoreRoundTrip(t *testing.T) {
+	remote := newGitStoreRemote(t)
+	first, second := t.TempDir(), t.TempDir()
+
+	useGitStoreHome(t, first, remote)
+	saveStoreCommands(t, defaultLibrary, Command{ID: "a", Command: "echo a"})
+	appDirPath, _ := getAppDirPath()
+	if err := ioutil.WriteFile(filepath.Join(appDirPath, "notes.txt"), []byte("local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := commitStoreChange("Save notes"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncGitStore(); err != nil {
+		t.Fatal(err)
+	}
+
+	useGitStoreHome(t, second, remote)
+	if err := syncGitStore(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := readStoreCommands(t, defaultLibrary)["a"]; !ok {
+		t.Fatal("pulled store misses command a")
+	}
+	appDirPath, _ = getAppDirPath()
+	if _, err := os.Stat(filepath.Join(appDirPath, "notes.txt")); !os.IsNotExist(err) {
+		t.Fatal("file other than commands files was committed")
+	}
+	saveStoreCommands(t, defaultLibrary, Command{ID: "a", Command: "echo a"}, Command{ID: "b", Command: "echo b"})
+	if err := syncGitStore(); err != nil {
+		t.Fatal(err)
+	}
+
+	useGitStoreHome(t, first, remote)
+	if err := syncGitStore(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := readStoreCommands(t, defaultLibrary)["b"]; !ok {
+		t.Fatal("pushed command b is not pulled back")
+	}
+}
+
+func TestGitStoreConflict(t *testing.T) {
+	remote := newGitStoreRemote(t)
+	first, second := t.TempDir(), t.TempDir()
+	defer func(mode string) { conflictMode = mode }(conflictMode)
+	conflictMode = conflictModeLocal
+
+	useGitStoreHome(t, first, remote)
+	saveStoreCommands(t, defaultLibrary, Command{ID: "a", Command: "echo a", Description: "base"})
+	if err := syncGitStore(); err != nil {
+		t.Fatal(err)
+	}
+	useGitStoreHome(t, second, remote)
+	if err := syncGitStore(); err != nil {
+		t.Fatal(err)
+	}
+
+	// both sides change the same command, first also changes another library
+	useGitStoreHome(t, first, remote)
+	saveStoreCommands(t, defaultLibrary, Command{ID: "a", Command: "echo a", Description: "first"}, Command{ID: "c", Command: "echo c"})
+	saveStoreCommands(t, "work", Command{ID: "w", Command: "echo work"})
+	if err := syncGitStore(); err != nil {
+		t.Fatal(err)
+	}
+	useGitStoreHome(t, second, remote)
+	saveStoreCommands(t, defaultLibrary, Command{ID: "a", Command: "echo a", Description: "second"}, Command{ID: "d", Command: "echo d"})
+	if err := syncGitStore(); err != nil {
+		t.Fatal(err)
+	}
+
+	commands := readStoreCommands(t, defaultLibrary)
+	if commands["a"].Description != "second" {
+		t.Errorf("conflict is resolved to %q, want the local version", commands["a"].Description)
+	}
+	for _, id := range []string{"c", "d"} {
+		if _, ok := commands[id]; !ok {
+			t.Errorf("merged commands miss %s", id)
+		}
+	}
+	if _, ok := readStoreCommands(t, "work")["w"]; !ok {
+		t.Error("library changed only remotely is lost in the merge")
+	}
+
+	// the merge is pushed, and nothing of the first side is overwritten
+	useGitStoreHome(t, first, remote)
+	if err := syncGitStore(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := readStoreCommands(t, "work")["w"]; !ok {
+		t.Error("library is overwritten on remote")
+	}
+	if _, ok := readStoreCommands(t, defaultLibrary)["d"]; !ok {
+		t.Error("merged command d is not pushed")
+	}
+}
+
+func TestGitStoreRefusesUnresolvedConflicts(t *testing.T) {
+	remote := newGitStoreRemote(t)
+	useGitStoreHome(t, t.TempDir(), remote)
+	saveStoreCommands(t, defaultLibrary, Command{ID: "a", Command: "echo a"})
+	saveStoreCommands(t, "work", Command{ID: "w", Command: "echo work"})
+	conflictsFilePath, err := getConflictsFilePath("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writeConflictsFile(conflictsFilePath, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = syncGitStore(); err == nil {
+		t.Fatal("sync ran with unresolved conflicts of another library")
+	}
+	if err = os.Remove(conflictsFilePath); err != nil {
+		t.Fatal(err)
+	}
+	if err = syncGitStore(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -23,12 +23,15 @@ package cmd
 
 import (
 	"fmt"
-	"regexp"
+	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
+var grepFormat string
+
 // grepCmd represents the grep command
 var grepCmd = &cobra.Command{
 	Use:   "grep [ARGS]",
@@ -49,17 +52,16 @@ var grepCmd = &cobra.Command{
 				fmt.Println("error occured while creating commands file: ", err)
 				return
 			}
-			fmt.Println(warningCommandsFileNotExist)
-			return
 		}
 
 		// concatenate args into the single string
 		concatenatedArgs := strings.Join(args[:], " ")
-		commands, err := getCommands()
+		commands, err := getAllCommands()
 		if err != nil {
 			fmt.Println("error while getting commands:", err)
 			return
 		}
+		commands.Commands = applyScopes(commands.Commands, allScopes)
 
 		if len(commands.Commands) == 0 {
 			fmt.Println(warningCommandsFileNotExist)
@@ -67,28 +69,44 @@ var grepCmd = &cobra.Command{
 		}
 
 		var concatenatedCommands, matches []string
+		var matchedCommands []Command
 		for _, command := range commands.Commands {
-			concatenatedCommands = append(concatenatedCommands, command.Command+" :: "+command.Description+" :: "+command.Alias)
+			concatenatedCommands = append(concatenatedCommands, getCommandText(command)+" :: "+command.Description+" :: "+command.Alias)
 		}
-		for _, concatenatedCommand := range concatenatedCommands {
-			isMatched, err := regexp.MatchString(concatenatedArgs, concatenatedCommand)
-			if err != nil {
-				fmt.Println("error while searching argument:", err)
-				return
-			} else if isMatched {
+		matchCommand, err := newCommandMatcher(concatenatedArgs)
+		if err != nil {
+			fmt.Println("error while searching argument:", err)
+			return
+		}
+		for i, concatenatedCommand := range concatenatedCommands {
+			if matchCommand(concatenatedCommand) {
 				matches = append(matches, concatenatedCommand)
+				matchedCommands = append(matchedCommands, commands.Commands[i])
 			}
 		}
 		if len(matches) == 0 {
 			fmt.Println("No saved commands matches the pattern: ", concatenatedArgs)
 			return
 		}
+		if grepFormat == "" {
+			grepFormat = viper.GetString("output.format")
+		}
+		if grepFormat != "" {
+			err = printCommandsWithTemplate(os.Stdout, matchedCommands, grepFormat)
+			if err != nil {
+				fmt.Println("format error:", err)
+			}
+			return
+		}
 		fmt.Printf("Command(s) found: \n\n")
-		fmt.Println(strings.Join(matches[:], "\n"))
+		for _, command := range matchedCommands {
+			fmt.Println(formatCommandLine(command))
+		}
 		return
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(grepCmd)
+	grepCmd.Flags().StringVarP(&grepFormat, "format", "f", "", "Go template (or name of a template in config file) used to print each match")
 }
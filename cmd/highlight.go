@@ -0,0 +1,205 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+var colorMode string
+
+// Colors of the token types of a highlighted command
+var (
+	keywordColor     = color.New(color.FgMagenta, color.Bold)
+	flagColor        = color.New(color.FgCyan)
+	stringColor      = color.New(color.FgGreen)
+	operatorColor    = color.New(color.FgYellow)
+	variableColor    = color.New(color.FgBlue)
+	placeholderColor = color.New(color.FgRed, color.Underline)
+	commentColor     = color.New(color.FgHiBlack)
+)
+
+// Shell reserved words that are highlighted in command position
+var shellKeywords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true,
+	"for": true, "while": true, "until": true, "do": true, "done": true,
+	"case": true, "esac": true, "in": true, "function": true, "select": true,
+	"time": true, "!": true, "{": true, "}": true, "[[": true, "]]": true,
+}
+
+// Placeholders in <name> or <name=default> form
+var placeholderPattern = regexp.MustCompile(`^<[A-Za-z_][A-Za-z0-9_.-]*(=[^<>\n]*)?>`)
+
+// Shell variables like $HOME, ${HOME}, $1, $? and $@
+var variablePattern = regexp.MustCompile(`^\$(\{[^}]*\}|[A-Za-z_][A-Za-z0-9_]*|[0-9@*#?$!-])`)
+
+// Shell control operators and redirections, longest first
+var shellOperators = []string{"$(", "&&", "||", ";;", ">>", "2>&1", "&>", "|&", "|", "&", ";", ">", "<", "(", ")"}
+
+// Enables or disables colored output according to --color and NO_COLOR
+func configureColor() error {
+	switch colorMode {
+	case "", "auto":
+		_, noColor := os.LookupEnv("NO_COLOR")
+		color.NoColor = noColor || os.Getenv("TERM") == "dumb" || !isStdoutTerminal()
+	case "always":
+		color.NoColor = false
+	case "never":
+		color.NoColor = true
+	default:
+		return fmt.Errorf("invalid color mode %q (use auto, always or never)", colorMode)
+	}
+	return nil
+}
+
+// Returns command with ANSI colors for keywords, flags, strings, operators,
+// variables and placeholders. Returns command unchanged if colors are disabled.
+func highlightCommand(command string) string {
+	if color.NoColor {
+		return command
+	}
+	var sb strings.Builder
+	commandPosition := true
+	for i := 0; i < len(command); {
+		rest := command[i:]
+		c := command[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if c == '\n' {
+				commandPosition = true
+			}
+			sb.WriteByte(c)
+			i++
+		case c == '#' && (i == 0 || strings.ContainsRune(" \t\n", rune(command[i-1]))):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			sb.WriteString(commentColor.Sprint(rest[:end]))
+			i += end
+		case c == '\'' || c == '"':
+			end := quotedStringEnd(rest)
+			sb.WriteString(stringColor.Sprint(rest[:end]))
+			i += end
+			commandPosition = false
+		case c == '$' && variablePattern.MatchString(rest):
+			match := variablePattern.FindString(rest)
+			sb.WriteString(variableColor.Sprint(match))
+			i += len(match)
+			commandPosition = false
+		case c == '<' && placeholderPattern.MatchString(rest):
+			match := placeholderPattern.FindString(rest)
+			sb.WriteString(placeholderColor.Sprint(match))
+			i += len(match)
+			commandPosition = false
+		default:
+			if operator := shellOperatorPrefix(rest); operator != "" {
+				sb.WriteString(operatorColor.Sprint(operator))
+				i += len(operator)
+				commandPosition = operator != ">" && operator != ">>" && operator != "<" &&
+					operator != "&>" && operator != "2>&1" && operator != ")"
+				continue
+			}
+			end := wordEnd(rest)
+			word := rest[:end]
+			switch {
+			case commandPosition && shellKeywords[word]:
+				sb.WriteString(keywordColor.Sprint(word))
+				// a new command follows most keywords
+				commandPosition = word != "in" && word != "for" && word != "case" && word != "select" && word != "function"
+			case strings.HasPrefix(word, "-") && len(word) > 1:
+				sb.WriteString(flagColor.Sprint(word))
+				commandPosition = false
+			default:
+				sb.WriteString(word)
+				commandPosition = false
+			}
+			i += end
+		}
+	}
+	return sb.String()
+}
+
+// Returns the length of the quoted string at the beginning of s, including
+// both quotes. Unterminated strings run until the end of s.
+func quotedStringEnd(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		if s[i] == '\\' && quote == '"' {
+			i++
+			continue
+		}
+		if s[i] == quote {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// Returns the shell operator at the beginning of s, if any
+func shellOperatorPrefix(s string) string {
+	for _, operator := range shellOperators {
+		if strings.HasPrefix(s, operator) {
+			return operator
+		}
+	}
+	return ""
+}
+
+// Returns the length of the plain word at the beginning of s
+func wordEnd(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case ' ', '\t', '\n', '\'', '"', '$', '|', '&', ';', '>', '<', '(', ')':
+			if i == 0 {
+				return 1
+			}
+			return i
+		case '\\':
+			i++
+		}
+	}
+	return len(s)
+}
+
+// Formats command as a single "command :: description :: alias" line with
+// the command highlighted. Commands which do not come from user's own
+// commands file are prefixed with their layer. Following lines of a script
+// are indented below the first one.
+func formatCommandLine(command Command) string {
+	lines := strings.Split(strings.TrimRight(getCommandText(command), "\n"), "\n")
+	line := highlightCommand(lines[0]) + " :: " + command.Description + " :: " + command.Alias
+	for _, scriptLine := range lines[1:] {
+		line += "\n    " + highlightCommand(scriptLine)
+	}
+	if command.Layer != "" && command.Layer != userLayer {
+		line = "[" + command.Layer + "] " + line
+	} else if command.Library != "" && command.Library != getCurrentLibrary() {
+		line = "[" + command.Library + "] " + line
+	}
+	return line
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// ANSI color sequences
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestHighlightCommand(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = false
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"ls -la", "ls " + flagColor.Sprint("-la")},
+		{`echo "hi $USER"`, "echo " + stringColor.Sprint(`"hi $USER"`)},
+		{"echo $HOME", "echo " + variableColor.Sprint("$HOME")},
+		{"ssh <host=prod>", "ssh " + placeholderColor.Sprint("<host=prod>")},
+		{"cat a | wc -l", "cat a " + operatorColor.Sprint("|") + " wc " + flagColor.Sprint("-l")},
+		{"for f in x; do echo; done", keywordColor.Sprint("for") + " f in x" + operatorColor.Sprint(";") + " " +
+			keywordColor.Sprint("do") + " echo" + operatorColor.Sprint(";") + " " + keywordColor.Sprint("done")},
+		// keywords are only highlighted in command position
+		{"echo done", "echo done"},
+		{"make # build", "make " + commentColor.Sprint("# build")},
+	}
+	for _, test := range tests {
+		if highlighted := highlightCommand(test.command); highlighted != test.want {
+			t.Errorf("highlightCommand(%q) = %q, want %q", test.command, highlighted, test.want)
+		}
+		// highlighting only adds colors
+		if plain := ansiPattern.ReplaceAllString(highlightCommand(test.command), ""); plain != test.command {
+			t.Errorf("highlightCommand(%q) changed the text to %q", test.command, plain)
+		}
+	}
+
+	color.NoColor = true
+	if highlighted := highlightCommand("ls -la | wc"); highlighted != "ls -la | wc" {
+		t.Errorf("highlighted without colors: %q", highlighted)
+	}
+}
+
+func TestConfigureColor(t *testing.T) {
+	defer func(mode string, noColor bool) { colorMode, color.NoColor = mode, noColor }(colorMode, color.NoColor)
+	t.Setenv("NO_COLOR", "1")
+	tests := []struct {
+		mode    string
+		noColor bool
+	}{
+		{"auto", true},
+		{"always", false},
+		{"never", true},
+	}
+	for _, test := range tests {
+		colorMode = test.mode
+		if err := configureColor(); err != nil {
+			t.Fatal(err)
+		}
+		if color.NoColor != test.noColor {
+			t.Errorf("--color %s: NoColor is %v", test.mode, color.NoColor)
+		}
+	}
+	colorMode = "sometimes"
+	if err := configureColor(); err == nil {
+		t.Error("invalid color mode is accepted")
+	}
+}
@@ -0,0 +1,289 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var runsFileName = "runs.jsonl"
+
+// How long recording a run waits for the lock of run history, and the age
+// after which a lock is known to be left behind
+var (
+	runsLockTimeout  = 15 * time.Second
+	staleRunsLockAge = 10 * time.Second
+)
+
+// Exit codes recorded for runs katip killed, as timeout(1) and shells report
+// them
+const (
+	exitCodeTimedOut    = 124
+	exitCodeInterrupted = 130
+)
+
+// Run is an execution of a saved command, as it is kept in run history
+type Run struct {
+	ID        int               `json:"id"`
+	CommandID string            `json:"command_id"`
+	Library   string            `json:"library,omitempty"`
+	Command   string            `json:"command"`
+	Values    map[string]string `json:"values,omitempty"`
+	Dir       string            `json:"dir"`
+	Host      string            `json:"host"`
+	StartedAt time.Time         `json:"started_at"`
+	Duration  time.Duration     `json:"duration"`
+	ExitCode  int               `json:"exit_code"`
+	Captured  bool              `json:"captured,omitempty"`
+}
+
+// Returns path of the file run history is kept in
+func getRunsFilePath() (string, error) {
+	stateDirPath, err := getStateDirPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDirPath, runsFileName), nil
+}
+
+// Returns recorded runs, oldest first
+func getRuns() ([]Run, error) {
+	runsFilePath, err := getRunsFilePath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(runsFilePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var runs []Run
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var run Run
+		if err = json.Unmarshal([]byte(line), &run); err != nil {
+			return nil, fmt.Errorf("invalid run in %s: %v", runsFilePath, err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, scanner.Err()
+}
+
+// Returns the recorded run with id
+func findRun(id string) (Run, error) {
+	runID, err := strconv.Atoi(strings.TrimPrefix(id, "#"))
+	if err != nil {
+		return Run{}, fmt.Errorf("invalid run ID %q", id)
+	}
+	runs, err := getRuns()
+	if err != nil {
+		return Run{}, err
+	}
+	for _, run := range runs {
+		if run.ID == runID {
+			return run, nil
+		}
+	}
+	return Run{}, fmt.Errorf("no run with ID %d", runID)
+}
+
+// Returns a run of command starting now, in the current directory
+func startRun(command Command) Run {
+	dir, _ := os.Getwd()
+	host, _ := os.Hostname()
+	return Run{
+		CommandID: command.ID,
+		Library:   command.Library,
+		Command:   getCommandText(command),
+		Dir:       dir,
+		Host:      host,
+		StartedAt: time.Now(),
+	}
+}
+
+// Takes the lock of run history, waiting for other katip processes to
+// release it. A lock older than staleRunsLockAge is left by a process which
+// died and is taken over. The returned function releases the lock.
+func lockRuns(runsFilePath string) (func(), error) {
+	lockPath := runsFilePath + ".lock"
+	deadline := time.Now().Add(runsLockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleRunsLockAge {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("run history is locked, remove %s if no katip is running", lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Appends run to run history, finishing it with the result of running it
+// and giving it an ID. The oldest runs are removed once there are more than
+// history.max_runs of them.
+func recordRun(run *Run, runErr error) error {
+	run.Duration = time.Since(run.StartedAt)
+	run.ExitCode = getExitCode(runErr)
+	maxRuns, err := strconv.Atoi(viper.GetString("history.max_runs"))
+	if err != nil {
+		return fmt.Errorf("invalid history.max_runs: %v", err)
+	}
+	runsFilePath, err := getRunsFilePath()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(runsFilePath), 0755); err != nil {
+		return err
+	}
+	// IDs are given under the lock, so that runs ending at the same time
+	// get different ones
+	unlock, err := lockRuns(runsFilePath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	runs, err := getRuns()
+	if err != nil {
+		return err
+	}
+	run.ID = 1
+	if len(runs) > 0 {
+		run.ID = runs[len(runs)-1].ID + 1
+	}
+	if maxRuns > 0 && len(runs) >= maxRuns {
+		return writeRuns(runsFilePath, append(runs[len(runs)-maxRuns+1:], *run))
+	}
+	line, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(runsFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Replaces run history with runs. The file is replaced at once, so that it
+// is never left half written.
+func writeRuns(runsFilePath string, runs []Run) error {
+	var buf bytes.Buffer
+	for _, run := range runs {
+		line, err := json.Marshal(run)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	tmpPath := runsFilePath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, runsFilePath)
+}
+
+// Returns the exit code a run which ended with err is recorded with
+func getExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var killed *killedError
+	if errors.As(err, &killed) {
+		if killed.interrupted {
+			return exitCodeInterrupted
+		}
+		return exitCodeTimedOut
+	}
+	if status, ok := getWaitStatus(err); ok && status.Signaled() {
+		// as shells report commands killed by a signal
+		return 128 + int(status.Signal())
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// Parses an age such as 12h, 1d or 2w. Days and weeks are added to the
+// units time.ParseDuration knows.
+func parseAge(age string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if count, err := strconv.Atoi(strings.TrimSuffix(age, suffix)); err == nil && strings.HasSuffix(age, suffix) {
+			return time.Duration(count) * unit, nil
+		}
+	}
+	return time.ParseDuration(age)
+}
+
+// Parses how far back --since goes, either an age such as 12h, 1d or 2w, or
+// a date such as 2006-01-02. Returns the earliest time it covers.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", since, time.Local); err == nil {
+		return t, nil
+	}
+	d, err := parseAge(since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since %q (use a duration such as 12h, 1d or 2w, or a date such as 2006-01-02)", since)
+	}
+	return now.Add(-d), nil
+}
+
+// Formats duration of a run for tables
+func formatRunDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
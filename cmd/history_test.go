@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestRecordRunGivesUniqueIDs(t *testing.T) {
+	t.Setenv(homeEnvVariable, t.TempDir())
+	viper.Set("history.max_runs", "0")
+	defer viper.Set("history.max_runs", nil)
+
+	const count = 100
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- recordRun(&Run{Command: "true", StartedAt: time.Now()}, nil)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs, err := getRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[int]bool{}
+	for _, run := range runs {
+		if seen[run.ID] {
+			t.Fatalf("run ID %d is given twice", run.ID)
+		}
+		seen[run.ID] = true
+	}
+	if len(runs) != count {
+		t.Fatalf("%d runs are recorded, want %d", len(runs), count)
+	}
+}
+
+func TestRecordRunKeepsMaxRuns(t *testing.T) {
+	t.Setenv(homeEnvVariable, t.TempDir())
+	viper.Set("history.max_runs", "3")
+	defer viper.Set("history.max_runs", nil)
+
+	for i := 0; i < 5; i++ {
+		if err := recordRun(&Run{Command: "true", StartedAt: time.Now()}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs, err := getRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 3 || runs[0].ID != 3 || runs[2].ID != 5 {
+		t.Fatalf("runs after trimming: %+v", runs)
+	}
+	if _, err = findRun("2"); err == nil {
+		t.Error("removed run is still found")
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Commands file stored as a single object on an HTTP server. ETags of the
+// object are used for optimistic concurrency: updates are sent with If-Match
+// and creations with If-None-Match so concurrent changes are never
+// overwritten.
+type httpFileRemote struct {
+	name   string
+	url    string
+	client *http.Client
+	// authorize adds credentials to req, payload is the request body
+	authorize func(req *http.Request, payload []byte) error
+	// prepare is called once before the first write, for example to create
+	// missing parent directories
+	prepare func(r *httpFileRemote) error
+}
+
+func newHTTPFileRemote(name, url string) *httpFileRemote {
+	return &httpFileRemote{
+		name:   name,
+		url:    url,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (r *httpFileRemote) Name() string {
+	return r.name
+}
+
+// Sends request to the remote file
+func (r *httpFileRemote) do(method, url string, payload []byte, header http.Header) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if r.authorize != nil {
+		if err = r.authorize(req, payload); err != nil {
+			return nil, nil, err
+		}
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return resp, body, err
+}
+
+// Returns an error describing an unexpected response
+func httpStatusError(method, url string, resp *http.Response, body []byte) error {
+	message := strings.TrimSpace(string(body))
+	if len(message) > 200 {
+		message = message[:200] + "..."
+	}
+	return fmt.Errorf("%s %s: %s %s", method, url, resp.Status, message)
+}
+
+// Returns commands in the remote file and its ETag, or nil if the file does
+// not exist yet
+func (r *httpFileRemote) Fetch() (*Commands, string, error) {
+	resp, body, err := r.do("GET", r.url, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", httpStatusError("GET", r.url, resp, body)
+	}
+	var commands Commands
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err = json.Unmarshal(body, &commands); err != nil {
+			return nil, "", fmt.Errorf("invalid commands file at %s: %v", r.url, err)
+		}
+	}
+	return &commands, resp.Header.Get("ETag"), nil
+}
+
+// Uploads commands if the remote file is still at version
+func (r *httpFileRemote) Store(commands *Commands, version string) error {
+	payload, err := json.MarshalIndent(commands, "", "  ")
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	if version != "" {
+		header.Set("If-Match", version)
+	} else {
+		header.Set("If-None-Match", "*")
+	}
+	if r.prepare != nil && version == "" {
+		if err = r.prepare(r); err != nil {
+			return err
+		}
+	}
+	resp, body, err := r.do("PUT", r.url, payload, header)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return errRemoteChanged
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return httpStatusError("PUT", r.url, resp, body)
+	}
+	return nil
+}
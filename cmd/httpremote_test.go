@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// In-memory HTTP file server honouring If-Match and If-None-Match like
+// WebDAV servers and S3 do. MKCOL creates collections when webdav is set.
+type fakeFileServer struct {
+	mu          sync.Mutex
+	webdav      bool
+	files       map[string]string
+	revisions   map[string]int
+	collections map[string]bool
+	requests    []*http.Request
+}
+
+func newFakeFileServer(t *testing.T, webdav bool) (*fakeFileServer, *httptest.Server) {
+	fake := &fakeFileServer{
+		webdav:      webdav,
+		files:       map[string]string{},
+		revisions:   map[string]int{},
+		collections: map[string]bool{"/": true},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeFileServer) etag(name string) string {
+	return fmt.Sprintf(`"%d"`, f.revisions[name])
+}
+
+func (f *fakeFileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r)
+	name := r.URL.Path
+	content, exists := f.files[name]
+	switch r.Method {
+	case "MKCOL":
+		dir := strings.TrimSuffix(name, "/")
+		if f.collections[dir] {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !f.collections[path.Dir(dir)] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.collections[dir] = true
+		w.WriteHeader(http.StatusCreated)
+	case "GET":
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", f.etag(name))
+		fmt.Fprint(w, content)
+	case "PUT":
+		if f.webdav && !f.collections[path.Dir(name)] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+		if ifMatch != "" && (!exists || ifMatch != f.etag(name)) || ifNoneMatch == "*" && exists {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		f.files[name] = string(body)
+		f.revisions[name]++
+		w.Header().Set("ETag", f.etag(name))
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Updates a file behind the back of the remote
+func (f *fakeFileServer) change(name, content string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[name] = content
+	f.revisions[name]++
+}
+
+// Checks the first push, an update and an update of a changed file
+func testHTTPFileRemote(t *testing.T, remote syncRemote, fake *fakeFileServer, name string) {
+	commands, version, err := remote.Fetch()
+	if err != nil || commands != nil {
+		t.Fatalf("Fetch of a missing file = %v, %v", commands, err)
+	}
+	if err = remote.Store(&Commands{Commands: []Command{{ID: "a", Command: "echo a"}}}, version); err != nil {
+		t.Fatalf("first push: %v", err)
+	}
+	// a second first push must not overwrite the file
+	if err = remote.Store(&Commands{}, ""); err != errRemoteChanged {
+		t.Fatalf("creating an existing file = %v, want errRemoteChanged", err)
+	}
+
+	commands, version, err = remote.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commands.Commands) != 1 || version == "" {
+		t.Fatalf("Fetch = %+v, %q", commands, version)
+	}
+	commands.Commands = append(commands.Commands, Command{ID: "b", Command: "echo b"})
+	if err = remote.Store(commands, version); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	_, version, err = remote.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.change(name, `{"commands":[]}`)
+	if err = remote.Store(commands, version); err != errRemoteChanged {
+		t.Fatalf("update of a changed file = %v, want errRemoteChanged", err)
+	}
+	if fake.files[name] != `{"commands":[]}` {
+		t.Fatal("changed file is overwritten")
+	}
+}
+
+func TestHTTPFileRemoteStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "denied", http.StatusForbidden)
+	}))
+	defer server.Close()
+	remote := newHTTPFileRemote("test", server.URL+"/commands.json")
+	if _, _, err := remote.Fetch(); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Fetch = %v, want 403", err)
+	}
+	if err := remote.Store(&Commands{}, ""); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Store = %v, want 403", err)
+	}
+}
@@ -0,0 +1,374 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	toml "github.com/pelletier/go-toml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importFrom   string
+	importDryRun bool
+)
+
+// Parsers of the supported import formats. Each parser reads the file or
+// directory at path and returns the commands found in it.
+var commandImporters = map[string]func(path string) ([]Command, error){
+	"keep": importFromKeep,
+	"pet":  importFromPet,
+	"navi": importFromNavi,
+	"tldr": importFromTldr,
+}
+
+// Default library locations of the tools which have one
+var defaultImportPaths = map[string]string{
+	"keep": ".keep/commands.json",
+	"pet":  ".config/pet/snippet.toml",
+}
+
+// Parses keep commands file. Older keep versions store a description for
+// each command, newer ones store an object with description and alias.
+func importFromKeep(path string) ([]Command, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keepCommands map[string]json.RawMessage
+	if err = json.Unmarshal(file, &keepCommands); err != nil {
+		return nil, err
+	}
+	var commands []Command
+	for commandText, value := range keepCommands {
+		command := Command{Command: commandText}
+		var description string
+		if err := json.Unmarshal(value, &description); err == nil {
+			command.Description = description
+		} else {
+			var entry struct {
+				Desc  string `json:"desc"`
+				Alias string `json:"alias"`
+			}
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return nil, fmt.Errorf("invalid entry for %q: %v", commandText, err)
+			}
+			command.Description = entry.Desc
+			command.Alias = entry.Alias
+		}
+		commands = append(commands, command)
+	}
+	// json objects are unordered, keep the import stable
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Command < commands[j].Command
+	})
+	return commands, nil
+}
+
+// Parses pet snippet.toml file
+func importFromPet(path string) ([]Command, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var petSnippets struct {
+		Snippets []struct {
+			Description string   `toml:"description"`
+			Command     string   `toml:"command"`
+			Tag         []string `toml:"tag"`
+			Output      string   `toml:"output"`
+		} `toml:"snippets"`
+	}
+	if err = toml.Unmarshal(file, &petSnippets); err != nil {
+		return nil, err
+	}
+	var commands []Command
+	for _, snippet := range petSnippets.Snippets {
+		commands = append(commands, Command{
+			Command:      snippet.Command,
+			Description:  snippet.Description,
+			Tags:         snippet.Tag,
+			Placeholders: getPlaceholderNames(snippet.Command),
+		})
+	}
+	return commands, nil
+}
+
+// Returns files in path with the given extension. If path is a file, it is
+// returned as is.
+func findImportFiles(path string, extension string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(info.Name(), extension) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// Navi variable definitions like "$ branch: git branch"
+var naviVariablePattern = regexp.MustCompile(`^\$\s*([A-Za-z0-9_-]+)\s*:\s*(.*)$`)
+
+// Parses navi cheat files. Tags come from "%" lines, descriptions from "#"
+// lines and variable definitions from "$" lines.
+func importFromNavi(path string) ([]Command, error) {
+	files, err := findImportFiles(path, ".cheat")
+	if err != nil {
+		return nil, err
+	}
+	var commands []Command
+	for _, filePath := range files {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return nil, err
+		}
+		var tags []string
+		var description string
+		var commandLines []string
+		variables := map[string]string{}
+		var fileCommands []Command
+		flush := func() {
+			if len(commandLines) > 0 {
+				commandText := strings.Join(commandLines, "\n")
+				fileCommands = append(fileCommands, Command{
+					Command:      commandText,
+					Description:  description,
+					Tags:         tags,
+					Placeholders: getPlaceholderNames(commandText),
+				})
+			}
+			commandLines = nil
+			description = ""
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimRight(scanner.Text(), " \t")
+			switch {
+			case strings.HasPrefix(line, "%"):
+				flush()
+				tags = parseTags(strings.TrimPrefix(line, "%"))
+			case strings.HasPrefix(line, "#"):
+				flush()
+				description = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+			case strings.HasPrefix(line, ";"), strings.HasPrefix(line, "@"):
+				// comments and extended cheats are not supported
+			case naviVariablePattern.MatchString(line):
+				flush()
+				match := naviVariablePattern.FindStringSubmatch(line)
+				variables[match[1]] = strings.TrimSpace(match[2])
+			case strings.TrimSpace(line) == "":
+				flush()
+			default:
+				commandLines = append(commandLines, line)
+			}
+		}
+		flush()
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		// variables are shared by every cheat in the file, attach only the
+		// ones a command refers to
+		for _, command := range fileCommands {
+			for name, value := range variables {
+				if strings.Contains(command.Command, "<"+name+">") {
+					if command.Variables == nil {
+						command.Variables = map[string]string{}
+					}
+					command.Variables[name] = value
+				}
+			}
+			commands = append(commands, command)
+		}
+	}
+	return commands, nil
+}
+
+// tldr placeholders like {{path/to/file}}
+var tldrPlaceholderPattern = regexp.MustCompile(`\{\{(.*?)\}\}`)
+
+// Parses tldr pages. Page name is used as tag, "- " lines as descriptions
+// and backtick quoted lines as commands.
+func importFromTldr(path string) ([]Command, error) {
+	files, err := findImportFiles(path, ".md")
+	if err != nil {
+		return nil, err
+	}
+	var commands []Command
+	for _, filePath := range files {
+		file, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		page := strings.TrimSuffix(filepath.Base(filePath), ".md")
+		var description string
+		for _, line := range strings.Split(string(bytes.Replace(file, []byte("\r\n"), []byte("\n"), -1)), "\n") {
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(line, "# "):
+				page = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+			case strings.HasPrefix(line, "- "):
+				description = strings.TrimSuffix(strings.TrimSpace(strings.TrimPrefix(line, "- ")), ":")
+			case len(line) > 1 && strings.HasPrefix(line, "`") && strings.HasSuffix(line, "`"):
+				var placeholders []string
+				commandText := tldrPlaceholderPattern.ReplaceAllStringFunc(line[1:len(line)-1], func(placeholder string) string {
+					name := tldrPlaceholderName(placeholder[2 : len(placeholder)-2])
+					if !isStringInSlice(name, placeholders) {
+						placeholders = append(placeholders, name)
+					}
+					return "<" + name + ">"
+				})
+				commands = append(commands, Command{
+					Command:      commandText,
+					Description:  description,
+					Tags:         []string{page},
+					Placeholders: placeholders,
+				})
+				description = ""
+			}
+		}
+	}
+	return commands, nil
+}
+
+// Converts a tldr placeholder text into a katip placeholder name
+func tldrPlaceholderName(placeholder string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(placeholder))
+	name = strings.Trim(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') || name[0] == '-' {
+		name = "value_" + name
+	}
+	return name
+}
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import --from keep|pet|navi|tldr [PATH]",
+	Short: "Imports commands from other command savers",
+	Long: `Imports commands from keep, pet, navi or tldr. PATH may be omitted for keep
+(~/.keep/commands.json) and pet (~/.config/pet/snippet.toml). navi and tldr
+accept a single file or a directory of cheats/pages. Commands which are
+already saved are skipped.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// check if app directory exists
+		isAppDirExists, err := checkIfAppDirExists()
+		if err != nil || isAppDirExists == false {
+			// if app directory does not exist, call init command
+			initCmd.Run(cmd, args)
+			return
+		}
+
+		importer, ok := commandImporters[importFrom]
+		if !ok {
+			fmt.Println("Please specify source with --from keep, pet, navi or tldr")
+			return
+		}
+		var path string
+		if len(args) > 0 {
+			path = args[0]
+		} else if defaultPath, ok := defaultImportPaths[importFrom]; ok {
+			homeDir, err := getHomeDirPath()
+			if err != nil {
+				fmt.Println("error while getting home directory:", err)
+				return
+			}
+			path = filepath.Join(homeDir, defaultPath)
+		} else {
+			fmt.Println("Please specify path of the", importFrom, "cheats")
+			return
+		}
+
+		importedCommands, err := importer(path)
+		if err != nil {
+			fmt.Println("import error:", err)
+			return
+		}
+		saveImportedCommands(importedCommands, importDryRun)
+		return
+	},
+}
+
+// Saves imported commands skipping duplicates. In dry-run mode only a preview
+// of the commands to be saved is printed.
+func saveImportedCommands(importedCommands []Command, dryRun bool) {
+	commands, err := getOrCreateCommands()
+	if err != nil {
+		fmt.Println("get commands error:", err)
+		return
+	}
+	if dryRun {
+		// work on a copy so nothing is changed
+		commands = &Commands{Commands: append([]Command(nil), commands.Commands...)}
+	}
+	added := mergeNewCommands(commands, importedCommands)
+	skipped := len(importedCommands) - len(added)
+	if len(added) == 0 {
+		fmt.Printf("Nothing to import (%d duplicate(s) skipped)\n", skipped)
+		return
+	}
+	if dryRun {
+		printCommandsAsTable(added, []string{"command", "description", "alias", "tags"})
+		fmt.Printf("%d command(s) would be imported, %d duplicate(s) skipped\n", len(added), skipped)
+		return
+	}
+	err = writeCommandsToFile(*commands)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	commitStoreChangeOrWarn(fmt.Sprintf("Import %d command(s)", len(added)))
+	fmt.Printf("%d command(s) imported, %d duplicate(s) skipped\n", len(added), skipped)
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringVar(&importFrom, "from", "", "source format: keep, pet, navi or tldr")
+	importCmd.PersistentFlags().BoolVar(&importDryRun, "dry-run", false, "show what would be imported without saving")
+}
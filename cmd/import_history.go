@@ -0,0 +1,384 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyShells   []string
+	historyTop      int
+	historyMinCount int
+)
+
+// Commands which are not worth saving however often they are used
+var trivialCommands = map[string]bool{
+	"ls": true, "ll": true, "la": true, "cd": true, "pwd": true, "clear": true,
+	"exit": true, "history": true, "cat": true, "less": true, "more": true,
+	"man": true, "vim": true, "vi": true, "nano": true, "emacs": true,
+	"echo": true, "which": true, "mkdir": true, "rm": true, "touch": true,
+	"code": true, "open": true, "katip": true,
+}
+
+// A command found in shell history with the number of times it was used
+type historyEntry struct {
+	Command string
+	Count   int
+	Score   int
+}
+
+// Parsers of the supported shell history files
+var historyParsers = map[string]func(data []byte) []string{
+	"bash": parseBashHistory,
+	"zsh":  parseZshHistory,
+	"fish": parseFishHistory,
+}
+
+// Returns history file path of shell, honouring HISTFILE for bash and zsh
+func getHistoryFilePath(shell string) (string, error) {
+	homeDir, err := getHomeDirPath()
+	if err != nil {
+		return "", err
+	}
+	histFile := os.Getenv("HISTFILE")
+	switch shell {
+	case "bash":
+		if histFile != "" && strings.Contains(histFile, "bash") {
+			return histFile, nil
+		}
+		return filepath.Join(homeDir, ".bash_history"), nil
+	case "zsh":
+		if histFile != "" && strings.Contains(histFile, "zsh") {
+			return histFile, nil
+		}
+		return filepath.Join(homeDir, ".zsh_history"), nil
+	case "fish":
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if dataHome == "" {
+			dataHome = filepath.Join(homeDir, ".local", "share")
+		}
+		return filepath.Join(dataHome, "fish", "fish_history"), nil
+	}
+	return "", fmt.Errorf("unsupported shell %q", shell)
+}
+
+// Timestamp lines in bash history like "#1600000000"
+var bashTimestampPattern = regexp.MustCompile(`^#[0-9]+$`)
+
+// Parses bash history, skipping timestamp lines written by HISTTIMEFORMAT
+func parseBashHistory(data []byte) []string {
+	var commands []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if bashTimestampPattern.MatchString(line) {
+			continue
+		}
+		commands = append(commands, line)
+	}
+	return commands
+}
+
+// Extended zsh history entries like ": 1600000000:0;git status"
+var zshExtendedPattern = regexp.MustCompile(`^: *[0-9]+:[0-9]+;`)
+
+// Parses zsh history in simple or extended format. Lines ending with a
+// backslash continue on the next line.
+func parseZshHistory(data []byte) []string {
+	var commands []string
+	var current []string
+	for _, line := range strings.Split(string(unmetafyZsh(data)), "\n") {
+		if len(current) == 0 {
+			line = zshExtendedPattern.ReplaceAllString(line, "")
+		}
+		if strings.HasSuffix(line, "\\") {
+			current = append(current, line)
+			continue
+		}
+		current = append(current, line)
+		commands = append(commands, strings.Join(current, "\n"))
+		current = nil
+	}
+	if len(current) > 0 {
+		commands = append(commands, strings.Join(current, "\n"))
+	}
+	return commands
+}
+
+// Reverts zsh's metafied encoding of non-ASCII bytes in history files
+func unmetafyZsh(data []byte) []byte {
+	const meta = 0x83
+	if bytes.IndexByte(data, meta) < 0 {
+		return data
+	}
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == meta && i+1 < len(data) {
+			i++
+			out = append(out, data[i]^32)
+			continue
+		}
+		out = append(out, data[i])
+	}
+	return out
+}
+
+// Parses fish history which stores entries as "- cmd: ..." lines
+func parseFishHistory(data []byte) []string {
+	var commands []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "- cmd: ") {
+			continue
+		}
+		command := strings.TrimPrefix(line, "- cmd: ")
+		command = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(command)
+		commands = append(commands, command)
+	}
+	return commands
+}
+
+// Returns a score for how complex command is. Pipes, chained commands and
+// long flag lists make a command harder to remember.
+func commandComplexity(command string) int {
+	complexity := 2*strings.Count(command, "|") + 2*strings.Count(command, "&&") + strings.Count(command, ";")
+	for _, field := range strings.Fields(command) {
+		if strings.HasPrefix(field, "-") {
+			complexity++
+		}
+	}
+	return complexity + len(command)/40
+}
+
+// Checks if command is too simple to be worth saving, which is the case for
+// a bare command in trivialCommands, with or without sudo. Pipelines, lists,
+// redirections and substitutions are never trivial.
+func isTrivialCommand(command string) bool {
+	if strings.ContainsAny(command, "|&;<>`") || strings.Contains(command, "$(") {
+		return false
+	}
+	fields := strings.Fields(command)
+	for len(fields) > 0 && fields[0] == "sudo" {
+		fields = fields[1:]
+	}
+	return len(fields) == 0 || trivialCommands[fields[0]]
+}
+
+// Collapses duplicate commands and ranks them by frequency and complexity
+func rankHistory(commands []string, minCount int) []historyEntry {
+	counts := map[string]int{}
+	var order []string
+	for _, command := range commands {
+		command = strings.TrimSpace(command)
+		if command == "" || isTrivialCommand(command) {
+			continue
+		}
+		if counts[command] == 0 {
+			order = append(order, command)
+		}
+		counts[command]++
+	}
+	var entries []historyEntry
+	for _, command := range order {
+		if counts[command] < minCount {
+			continue
+		}
+		entries = append(entries, historyEntry{
+			Command: command,
+			Count:   counts[command],
+			Score:   counts[command] * (1 + commandComplexity(command)),
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Score > entries[j].Score
+	})
+	return entries
+}
+
+// Parses selections like "1,3,5-7" or "all" into zero based indexes
+func parseIndexSelection(input string, count int) ([]int, error) {
+	input = strings.TrimSpace(input)
+	if input == "all" {
+		var indexes []int
+		for i := 0; i < count; i++ {
+			indexes = append(indexes, i)
+		}
+		return indexes, nil
+	}
+	var indexes []int
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		start, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection %q", part)
+		}
+		end := start
+		if len(bounds) == 2 {
+			end, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid selection %q", part)
+			}
+		}
+		if start < 1 || end > count || start > end {
+			return nil, fmt.Errorf("selection %q is out of range", part)
+		}
+		for i := start; i <= end; i++ {
+			if !isIntInSlice(i-1, indexes) {
+				indexes = append(indexes, i-1)
+			}
+		}
+	}
+	return indexes, nil
+}
+
+// historyImportCmd represents the import history command
+var historyImportCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Imports frequently used commands from shell history",
+	Long: `Reads bash, zsh and fish history, collapses duplicates and ranks commands by
+how often they are used and how complex they are. Trivial commands like ls
+and cd are left out, unless they are part of a pipeline or list. Pick the ones
+you want to save and describe them.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// check if app directory exists
+		isAppDirExists, err := checkIfAppDirExists()
+		if err != nil || isAppDirExists == false {
+			// if app directory does not exist, call init command
+			initCmd.Run(cmd, args)
+			return
+		}
+
+		var historyCommands []string
+		for _, shell := range historyShells {
+			parser, ok := historyParsers[shell]
+			if !ok {
+				fmt.Printf("unsupported shell %q (use bash, zsh or fish)\n", shell)
+				return
+			}
+			historyFilePath, err := getHistoryFilePath(shell)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			data, err := ioutil.ReadFile(historyFilePath)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				fmt.Println("error while reading history:", err)
+				return
+			}
+			historyCommands = append(historyCommands, parser(data)...)
+		}
+
+		commands, err := getOrCreateCommands()
+		if err != nil {
+			fmt.Println("get commands error:", err)
+			return
+		}
+		var candidates []historyEntry
+		for _, entry := range rankHistory(historyCommands, historyMinCount) {
+			if findCommandIndex(commands, entry.Command) < 0 {
+				candidates = append(candidates, entry)
+			}
+		}
+		if historyTop > 0 && len(candidates) > historyTop {
+			candidates = candidates[:historyTop]
+		}
+		if len(candidates) == 0 {
+			fmt.Println("No new commands found in shell history")
+			return
+		}
+
+		var candidateRow []table.Row
+		for i, entry := range candidates {
+			candidateRow = append(candidateRow, table.Row{i + 1, entry.Count, highlightCommand(entry.Command)})
+		}
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(table.Row{"#", "Count", "Command"})
+		t.AppendRows(candidateRow)
+		t.Render()
+		if importDryRun {
+			return
+		}
+
+		scanner := bufio.NewScanner(os.Stdin)
+		fmt.Printf("Commands to save (e.g. 1,3,5-7 or all, empty to cancel): ")
+		scanner.Scan()
+		indexes, err := parseIndexSelection(scanner.Text(), len(candidates))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if len(indexes) == 0 {
+			fmt.Println("Aborted")
+			return
+		}
+
+		var selected []Command
+		for _, index := range indexes {
+			fmt.Printf("\n%s\n", highlightCommand(candidates[index].Command))
+			fmt.Printf("Description: ")
+			scanner.Scan()
+			description := scanner.Text()
+			fmt.Printf("Alias: ")
+			scanner.Scan()
+			alias := scanner.Text()
+			selected = append(selected, Command{
+				Command:     candidates[index].Command,
+				Description: description,
+				Alias:       alias,
+			})
+		}
+		added := mergeNewCommands(commands, selected)
+		err = writeCommandsToFile(*commands)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		commitStoreChangeOrWarn(fmt.Sprintf("Import %d command(s) from shell history", len(added)))
+		fmt.Printf("%d command(s) saved\n", len(added))
+		return
+	},
+}
+
+func init() {
+	importCmd.AddCommand(historyImportCmd)
+	historyImportCmd.Flags().StringSliceVar(&historyShells, "shell", []string{"bash", "zsh", "fish"}, "shells whose history is read")
+	historyImportCmd.Flags().IntVarP(&historyTop, "top", "n", 20, "maximum number of commands to offer")
+	historyImportCmd.Flags().IntVar(&historyMinCount, "min-count", 2, "minimum number of times a command must appear")
+}
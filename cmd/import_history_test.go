@@ -0,0 +1,37 @@
+package cmd
+
+import "testing"
+
+func TestIsTrivialCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		trivial bool
+	}{
+		{"ls", true},
+		{"ls -la /var/log", true},
+		{"cd ~/src", true},
+		{"sudo", true},
+		{"sudo ls /root", true},
+		{"sudo -i", false},
+		{"make", false},
+		{"htop", false},
+		{"terraform", false},
+		{"sudo make install", false},
+		{"sudo reboot", false},
+		{"git status", false},
+		{"cat access.log | awk '{print $1}' | sort | uniq -c", false},
+		{"echo aGVsbG8= | base64 -d", false},
+		{"cd ~/src && make", false},
+		{"mkdir -p build; cd build", false},
+		{"echo export PATH=$PATH > ~/.profile", false},
+		{"cat < input.txt", false},
+		{"rm -rf $(find . -name '*.tmp')", false},
+		{"echo `date`", false},
+		{"sudo cat /etc/shadow | grep root", false},
+	}
+	for _, test := range tests {
+		if trivial := isTrivialCommand(test.command); trivial != test.trivial {
+			t.Errorf("isTrivialCommand(%q) = %v, want %v", test.command, trivial, test.trivial)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var projectTag string
+
+// Project task files and the parsers which turn their tasks into commands.
+// Parsers get the project directory and the path of the task file.
+var projectImporters = []struct {
+	FileNames []string
+	Parse     func(dir, path string) ([]Command, error)
+}{
+	{[]string{"Makefile", "makefile", "GNUmakefile"}, importMakefile},
+	{[]string{"package.json"}, importPackageJSON},
+	{[]string{"justfile", "Justfile", ".justfile"}, importJustfile},
+	{[]string{"Taskfile.yml", "Taskfile.yaml", "taskfile.yml", "taskfile.yaml"}, importTaskfile},
+}
+
+// Make targets like "build:" or "test lint: deps". Variable assignments
+// (":=", "::=") are excluded by the parser.
+var makeTargetPattern = regexp.MustCompile(`^([A-Za-z0-9_./-][A-Za-z0-9_./ -]*?)\s*::?(\s|$|[^=])`)
+
+// Reads the block of "#" comments right above a line, without the markers
+func commentText(comments []string) string {
+	var lines []string
+	for _, comment := range comments {
+		comment = strings.TrimSpace(strings.TrimLeft(comment, "#"))
+		if comment != "" {
+			lines = append(lines, comment)
+		}
+	}
+	return strings.Join(lines, " ")
+}
+
+// Parses Makefile targets. Description is taken from a "## text" comment
+// after the target or the comments above it.
+func importMakefile(dir, path string) ([]Command, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var commands []Command
+	var comments []string
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			comments = append(comments, line)
+			continue
+		}
+		match := makeTargetPattern.FindStringSubmatch(line)
+		if match == nil || strings.HasPrefix(line, "\t") || strings.Contains(line, ":=") || strings.Contains(line, "?=") {
+			if !strings.HasPrefix(line, "\t") {
+				comments = nil
+			}
+			continue
+		}
+		description := commentText(comments)
+		if index := strings.Index(line, "##"); index >= 0 {
+			description = strings.TrimSpace(line[index+2:])
+		}
+		comments = nil
+		for _, target := range strings.Fields(match[1]) {
+			// skip special targets like .PHONY and pattern rules
+			if strings.HasPrefix(target, ".") || strings.Contains(target, "%") || seen[target] {
+				continue
+			}
+			seen[target] = true
+			commands = append(commands, Command{
+				Command:     "make -C " + shellQuote(dir) + " " + target,
+				Description: description,
+				Alias:       target,
+			})
+		}
+	}
+	return commands, scanner.Err()
+}
+
+// Parses package.json scripts. JSON has no comments, so the script itself is
+// used as description.
+func importPackageJSON(dir, path string) ([]Command, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var packageJSON struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err = json.Unmarshal(file, &packageJSON); err != nil {
+		return nil, err
+	}
+	var commands []Command
+	for _, name := range sortedKeys(packageJSON.Scripts) {
+		commands = append(commands, Command{
+			Command:     "npm --prefix " + shellQuote(dir) + " run " + name,
+			Description: packageJSON.Scripts[name],
+			Alias:       name,
+		})
+	}
+	return commands, nil
+}
+
+// Just recipes like "build target='debug' *flags:"
+var justRecipePattern = regexp.MustCompile(`^@?([A-Za-z_][A-Za-z0-9_-]*)((?:\s+[^:=\s]+(?:=(?:'[^']*'|"[^"]*"|[^\s:]+))?)*)\s*:([^=]|$)`)
+
+// Parses justfile recipes. Recipe parameters become placeholders and the
+// comments above a recipe become its description.
+func importJustfile(dir, path string) ([]Command, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var commands []Command
+	var comments []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			comments = append(comments, line)
+			continue
+		}
+		match := justRecipePattern.FindStringSubmatch(line)
+		if match == nil || strings.HasPrefix(match[1], "_") {
+			if strings.TrimSpace(line) == "" || !strings.HasPrefix(line, "[") {
+				comments = nil
+			}
+			continue
+		}
+		commandText := "just --justfile " + shellQuote(path) + " --working-directory " + shellQuote(dir) + " " + match[1]
+		for _, parameter := range strings.Fields(match[2]) {
+			parameter = strings.TrimLeft(parameter, "+*$")
+			if parts := strings.SplitN(parameter, "=", 2); len(parts) == 2 {
+				parameter = parts[0] + "=" + strings.Trim(parts[1], `'"`)
+			}
+			commandText += " <" + parameter + ">"
+		}
+		commands = append(commands, Command{
+			Command:      commandText,
+			Description:  commentText(comments),
+			Alias:        match[1],
+			Placeholders: getPlaceholderNames(commandText),
+		})
+		comments = nil
+	}
+	return commands, scanner.Err()
+}
+
+// Parses Taskfile tasks, skipping internal ones
+func importTaskfile(dir, path string) ([]Command, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var taskfile struct {
+		Tasks map[string]struct {
+			Desc     string `yaml:"desc"`
+			Summary  string `yaml:"summary"`
+			Internal bool   `yaml:"internal"`
+		} `yaml:"tasks"`
+	}
+	if err = yaml.Unmarshal(file, &taskfile); err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range taskfile.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var commands []Command
+	for _, name := range names {
+		task := taskfile.Tasks[name]
+		if task.Internal {
+			continue
+		}
+		description := task.Desc
+		if description == "" {
+			description = strings.TrimSpace(task.Summary)
+		}
+		commands = append(commands, Command{
+			Command:     "task -d " + shellQuote(dir) + " " + name,
+			Description: description,
+			Alias:       name,
+		})
+	}
+	return commands, nil
+}
+
+// Returns name of the project in dir, from package.json if there is one
+func getProjectName(dir string) string {
+	if file, err := ioutil.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		var packageJSON struct {
+			Name string `json:"name"`
+		}
+		if json.Unmarshal(file, &packageJSON) == nil && packageJSON.Name != "" {
+			return packageJSON.Name
+		}
+	}
+	return filepath.Base(dir)
+}
+
+// Quotes s for the shell if it contains special characters
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-./:=@%+,", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// projectImportCmd represents the import project command
+var projectImportCmd = &cobra.Command{
+	Use:   "project [DIR]",
+	Short: "Imports tasks of a project as commands",
+	Long: `Imports Makefile targets, package.json scripts, justfile recipes and Taskfile
+tasks found in DIR (current directory by default). Target names prefixed
+with the project name, like "webapp:build", become aliases. Comments become
+descriptions and the project name is used as tag.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// check if app directory exists
+		isAppDirExists, err := checkIfAppDirExists()
+		if err != nil || isAppDirExists == false {
+			// if app directory does not exist, call init command
+			initCmd.Run(cmd, args)
+			return
+		}
+
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		dir, err = filepath.Abs(dir)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		tag := projectTag
+		if tag == "" {
+			tag = getProjectName(dir)
+		}
+
+		var importedCommands []Command
+		for _, importer := range projectImporters {
+			for _, fileName := range importer.FileNames {
+				path := filepath.Join(dir, fileName)
+				if _, err := os.Stat(path); err != nil {
+					continue
+				}
+				projectCommands, err := importer.Parse(dir, path)
+				if err != nil {
+					fmt.Printf("error while reading %s: %s\n", fileName, err)
+					return
+				}
+				for _, command := range projectCommands {
+					command.Tags = []string{tag}
+					// bare task names like build are the same in every project
+					command.Alias = tag + ":" + command.Alias
+					importedCommands = append(importedCommands, command)
+				}
+				// a project has only one file of each kind
+				break
+			}
+		}
+		if len(importedCommands) == 0 {
+			fmt.Println("No Makefile, package.json, justfile or Taskfile found in", dir)
+			return
+		}
+		saveImportedCommands(importedCommands, importDryRun)
+		return
+	},
+}
+
+func init() {
+	importCmd.AddCommand(projectImportCmd)
+	projectImportCmd.Flags().StringVar(&projectTag, "tag", "", "tag for imported commands (default is the project name)")
+}
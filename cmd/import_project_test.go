@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Writes files into a new directory named name and returns its path
+func writeProject(t *testing.T, name string, files map[string]string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for fileName, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, fileName), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestProjectImporters(t *testing.T) {
+	dir := writeProject(t, "webapp", map[string]string{
+		"Makefile":     ".PHONY: build test\n\n# Builds the binary\nbuild: deps\n\tgo build\n\ntest: ## Runs the tests\n\tgo test\n\nVERSION := 1\n%.o: %.c\n\tcc\n",
+		"package.json": `{"name": "webapp", "scripts": {"start": "node server.js", "lint": "eslint ."}}`,
+		"justfile":     "# Deploys to an environment\ndeploy env='staging' *flags:\n\t./deploy.sh\n\n_helper:\n\ttrue\n",
+		"Taskfile.yml": "tasks:\n  fmt:\n    desc: Formats code\n  secret:\n    internal: true\n",
+	})
+	tests := []struct {
+		file  string
+		parse func(dir, path string) ([]Command, error)
+		want  []Command
+	}{
+		{"Makefile", importMakefile, []Command{
+			{Command: "make -C " + dir + " build", Description: "Builds the binary", Alias: "build"},
+			{Command: "make -C " + dir + " test", Description: "Runs the tests", Alias: "test"},
+		}},
+		{"package.json", importPackageJSON, []Command{
+			{Command: "npm --prefix " + dir + " run lint", Description: "eslint .", Alias: "lint"},
+			{Command: "npm --prefix " + dir + " run start", Description: "node server.js", Alias: "start"},
+		}},
+		{"justfile", importJustfile, []Command{{
+			Command:      "just --justfile " + filepath.Join(dir, "justfile") + " --working-directory " + dir + " deploy <env=staging> <flags>",
+			Description:  "Deploys to an environment",
+			Alias:        "deploy",
+			Placeholders: []string{"env", "flags"},
+		}}},
+		{"Taskfile.yml", importTaskfile, []Command{
+			{Command: "task -d " + dir + " fmt", Description: "Formats code", Alias: "fmt"},
+		}},
+	}
+	for _, test := range tests {
+		commands, err := test.parse(dir, filepath.Join(dir, test.file))
+		if err != nil {
+			t.Fatalf("%s: %v", test.file, err)
+		}
+		if !reflect.DeepEqual(commands, test.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", test.file, commands, test.want)
+		}
+	}
+}
+
+func TestProjectImportKeepsAliasesUnique(t *testing.T) {
+	t.Setenv(homeEnvVariable, t.TempDir())
+	appDirPath, err := getAppDirPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(appDirPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	makefile := "build:\n\tgo build\n\ntest:\n\tgo test\n"
+	api := writeProject(t, "api", map[string]string{"Makefile": makefile})
+	// a Makefile and package.json of the same project both have build
+	web := writeProject(t, "web", map[string]string{"Makefile": makefile, "package.json": `{"scripts": {"build": "vite build"}}`})
+	for _, dir := range []string{api, web} {
+		projectImportCmd.Run(projectImportCmd, []string{dir})
+	}
+
+	commands, err := getCommands()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var aliases []string
+	for _, command := range commands.Commands {
+		aliases = append(aliases, command.Alias)
+	}
+	want := []string{"api:build", "api:test", "web:build", "web:test", "web:build-2"}
+	if !reflect.DeepEqual(aliases, want) {
+		t.Errorf("aliases = %q, want %q", aliases, want)
+	}
+}
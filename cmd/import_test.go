@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImportDeclaresPlaceholders(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"snippet.toml": "[[snippets]]\n  description = \"ssh\"\n  command = \"ssh <user=root>@<host>\"\n",
+		"git.cheat":    "% git\n\n# checkout\ngit checkout <branch>\n\n$ branch: git branch\n",
+		"tar.md":       "# tar\n\n- Extract an archive:\n\n`tar xf {{path/to/file.tar}}`\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name string
+		read func(path string) ([]Command, error)
+		file string
+		want []string
+	}{
+		{"pet", importFromPet, "snippet.toml", []string{"user", "host"}},
+		{"navi", importFromNavi, "git.cheat", []string{"branch"}},
+		{"tldr", importFromTldr, "tar.md", []string{"path_to_file_tar"}},
+	}
+	for _, test := range tests {
+		commands, err := test.read(filepath.Join(dir, test.file))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(commands) != 1 {
+			t.Fatalf("%s: imported %d commands", test.name, len(commands))
+		}
+		if !reflect.DeepEqual(commands[0].Placeholders, test.want) {
+			t.Errorf("%s: placeholders = %q, want %q (command %q)", test.name, commands[0].Placeholders, test.want, commands[0].Command)
+		}
+	}
+}
@@ -51,19 +51,18 @@ func askForConfirmation(confirmationText string) bool {
 
 func createIfCommandsFileNotExist() bool {
 	// check if commands file is created. If not then create
-	home, err := os.UserHomeDir()
+	configDirPath, err := getAppDirPath()
 	if err != nil {
-		fmt.Printf("getting home directory failed with error: %s\n", err)
+		fmt.Printf("getting app directory failed with error: %s\n", err)
 		return false
 	}
-	configDirPath := home + "/" + appDirName
 	isAppDirExists, err := checkIfAppDirExists()
 	if err != nil {
 		fmt.Println(err)
 		return false
 	}
 	if isAppDirExists {
-		if askForConfirmation(confirmationTextForDeleteAppDirectory) == true {
+		if askForConfirmation(fmt.Sprintf(confirmationTextForDeleteAppDirectory, configDirPath)) == true {
 			// if user confirms recreate operation
 			err := os.RemoveAll(configDirPath)
 			if err != nil {
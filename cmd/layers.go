@@ -0,0 +1,168 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	systemCommandsDir       = "/etc/katip"
+	projectCommandsFileName = ".katip.json"
+)
+
+// Layers commands can come from, highest priority first
+var (
+	projectLayer = "project"
+	userLayer    = "user"
+	systemLayer  = "system"
+)
+
+// Reads a commands file. Missing or empty files have no commands.
+func readCommandsFile(path string) (*Commands, error) {
+	file, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) || (err == nil && len(strings.TrimSpace(string(file))) == 0) {
+		return &Commands{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var commands Commands
+	if err = json.Unmarshal(file, &commands); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &commands, nil
+}
+
+// Returns path of the project commands file found by walking up from the
+// current directory, or an empty string if there is none
+func findProjectCommandsFile() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, projectCommandsFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Returns commands of every layer. Commands of a layer hide the commands of
+// lower priority layers which have the same alias or the same command.
+func getAllCommands() (*Commands, error) {
+	libraries, err := getSearchedLibraries()
+	if err != nil {
+		return nil, err
+	}
+	type commandsLayer struct {
+		name    string
+		library string
+		path    string
+	}
+	layers := []commandsLayer{{name: projectLayer, path: findProjectCommandsFile()}}
+	for _, library := range libraries {
+		path, err := getLibraryCommandsFilePath(library)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, commandsLayer{userLayer, library, path})
+	}
+	layers = append(layers, commandsLayer{name: systemLayer, path: filepath.Join(systemCommandsDir, commandsFileName)})
+
+	var all Commands
+	aliases := map[string]bool{}
+	commandTexts := map[string]bool{}
+	for _, layer := range layers {
+		if layer.path == "" {
+			continue
+		}
+		commands, err := readCommandsFile(layer.path)
+		if err != nil {
+			return nil, err
+		}
+		if layer.name == userLayer {
+			// commands saved by older versions get their IDs in memory, they
+			// are saved by the next command which changes the file
+			ensureCommandIDs(commands)
+		}
+		// commands of a layer are hidden only by higher priority layers,
+		// never by each other
+		layerAliases := map[string]bool{}
+		layerCommandTexts := map[string]bool{}
+		for _, command := range commands.Commands {
+			commandText := strings.TrimSpace(getCommandText(command))
+			if commandTexts[commandText] || (command.Alias != "" && aliases[command.Alias]) {
+				continue
+			}
+			layerCommandTexts[commandText] = true
+			if command.Alias != "" {
+				layerAliases[command.Alias] = true
+			}
+			command.Layer = layer.name
+			command.Library = layer.library
+			if command.ID == "" {
+				// read-only layers may not have IDs, derive one from content
+				command.ID = layer.name + "-" + getDerivedCommandID(command)
+			}
+			all.Commands = append(all.Commands, command)
+		}
+		for alias := range layerAliases {
+			aliases[alias] = true
+		}
+		for commandText := range layerCommandTexts {
+			commandTexts[commandText] = true
+		}
+	}
+	return &all, nil
+}
+
+// Checks if commands come from more than one library
+func hasMultipleLibraries(commands []Command) bool {
+	current := getCurrentLibrary()
+	for _, command := range commands {
+		if command.Library != "" && command.Library != current {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if commands come from more than one layer
+func hasMultipleLayers(commands []Command) bool {
+	for _, command := range commands {
+		if command.Layer != userLayer {
+			return true
+		}
+	}
+	return false
+}
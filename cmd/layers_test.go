@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadingGivesIDsInMemory(t *testing.T) {
+	t.Setenv(homeEnvVariable, t.TempDir())
+	path, err := getLibraryCommandsFilePath(defaultLibrary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// saved by an older version, without IDs and times
+	saved := []byte(`{"commands": [
+		{"command": "echo one", "description": "", "alias": ""},
+		{"command": "echo one", "description": "again", "alias": ""},
+		{"command": "echo two", "description": "", "alias": "two"}
+	]}`)
+	if err = ioutil.WriteFile(path, saved, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := getAllCommands()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := getAllCommands()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]bool{}
+	for i, command := range first.Commands {
+		if command.ID == "" || ids[command.ID] {
+			t.Errorf("command %d has ID %q", i, command.ID)
+		}
+		ids[command.ID] = true
+		if second.Commands[i].ID != command.ID {
+			t.Errorf("command %d has ID %q, then %q", i, command.ID, second.Commands[i].ID)
+		}
+	}
+	if content, _ := ioutil.ReadFile(path); !bytes.Equal(content, saved) {
+		t.Fatalf("reading changed the file:\n%s", content)
+	}
+
+	// the next change saves the IDs that were shown
+	if err = markCommandAsUsed(first.Commands[2]); err != nil {
+		t.Fatal(err)
+	}
+	commands, err := readCommandsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, command := range commands.Commands {
+		if command.ID != first.Commands[i].ID {
+			t.Errorf("command %d is saved with ID %q, shown as %q", i, command.ID, first.Commands[i].ID)
+		}
+	}
+	if commands.Commands[2].UsageCount != 1 || commands.Commands[2].LastUsedAt.IsZero() {
+		t.Errorf("usage of the command is not saved: %+v", commands.Commands[2])
+	}
+	content, _ := ioutil.ReadFile(path)
+	if bytes.Contains(content, []byte("0001-01-01")) || bytes.Contains(content, []byte("created_at")) {
+		t.Errorf("unknown times are saved:\n%s", content)
+	}
+}
@@ -0,0 +1,267 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultLibrary   = "default"
+	librariesDirName = "libraries"
+)
+
+var (
+	libraryFlag  string
+	allLibraries bool
+)
+
+var libraryNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Returns the library in use. --library takes precedence over KATIP_LIBRARY,
+// which takes precedence over the library selected with "katip library use".
+func getCurrentLibrary() string {
+	if libraryFlag != "" {
+		return libraryFlag
+	}
+	if library := viper.GetString("library"); library != "" {
+		return library
+	}
+	return defaultLibrary
+}
+
+// Returns path of library's commands file relative to app directory. The
+// default library lives in the commands file the app always used.
+func getLibraryFileName(library string) string {
+	if library == defaultLibrary {
+		return commandsFileName
+	}
+	return filepath.Join(librariesDirName, library+".json")
+}
+
+// Returns commands file path of library
+func getLibraryCommandsFilePath(library string) (string, error) {
+	if !libraryNamePattern.MatchString(library) {
+		return "", fmt.Errorf("invalid library name: %q", library)
+	}
+	appDirPath, err := getAppDirPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDirPath, getLibraryFileName(library)), nil
+}
+
+// Returns names of all libraries, the default library first
+func getLibraryNames() ([]string, error) {
+	appDirPath, err := getAppDirPath()
+	if err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(filepath.Join(appDirPath, librariesDirName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), ".json")
+		if !file.IsDir() && name != file.Name() && libraryNamePattern.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{defaultLibrary}, names...), nil
+}
+
+// Returns libraries user commands are read from: the library in use, and
+// every other library after it when searching all libraries
+func getSearchedLibraries() ([]string, error) {
+	current := getCurrentLibrary()
+	if err := checkLibraryExists(current); err != nil {
+		return nil, err
+	}
+	if !allLibraries {
+		return []string{current}, nil
+	}
+	names, err := getLibraryNames()
+	if err != nil {
+		return nil, err
+	}
+	libraries := []string{current}
+	for _, name := range names {
+		if name != current {
+			libraries = append(libraries, name)
+		}
+	}
+	return libraries, nil
+}
+
+// Returns an error unless library is the default library or is created.
+// Libraries are created only by "katip library create", never by a mistyped
+// name.
+func checkLibraryExists(library string) error {
+	if library == defaultLibrary || libraryExists(library) {
+		return nil
+	}
+	return fmt.Errorf("no library named %s, create it with \"katip library create %s\"", library, library)
+}
+
+// Checks if library has a commands file
+func libraryExists(library string) bool {
+	path, err := getLibraryCommandsFilePath(library)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// libraryCmd represents the library command
+var libraryCmd = &cobra.Command{
+	Use:   "library",
+	Short: "Manages named command libraries",
+	Long: `Commands can be kept in separate named libraries, such as work and personal.
+The library in use is selected with --library, the KATIP_LIBRARY environment
+variable or "katip library use".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var libraryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists libraries",
+	Run: func(cmd *cobra.Command, args []string) {
+		names, err := getLibraryNames()
+		if err != nil {
+			fmt.Println("error while getting libraries:", err)
+			return
+		}
+		current := getCurrentLibrary()
+		for _, name := range names {
+			marker := " "
+			if name == current {
+				marker = "*"
+			}
+			path, _ := getLibraryCommandsFilePath(name)
+			commands, err := readCommandsFile(path)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Printf("%s %s (%d commands)\n", marker, name, len(commands.Commands))
+		}
+	},
+}
+
+var libraryCreateCmd = &cobra.Command{
+	Use:   "create NAME",
+	Short: "Creates an empty library",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := getLibraryCommandsFilePath(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if libraryExists(args[0]) {
+			fmt.Println("library already exists:", args[0])
+			return
+		}
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			fmt.Println("error while creating library:", err)
+			return
+		}
+		if err = ioutil.WriteFile(path, []byte(`{"commands":[]}`), 0644); err != nil {
+			fmt.Println("error while creating library:", err)
+			return
+		}
+		commitStoreChangeOrWarn("Create library " + args[0])
+		fmt.Println("Library is successfully created")
+	},
+}
+
+var libraryUseCmd = &cobra.Command{
+	Use:   "use NAME",
+	Short: "Selects the library commands are read from and saved to",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := checkLibraryExists(args[0]); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := saveConfigValue("library", args[0]); err != nil {
+			fmt.Println("error while saving config:", err)
+			return
+		}
+		fmt.Println("Using library", args[0])
+	},
+}
+
+var libraryDeleteCmd = &cobra.Command{
+	Use:   "delete NAME",
+	Short: "Deletes a library and its commands",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if args[0] == defaultLibrary {
+			fmt.Println("the default library cannot be deleted")
+			return
+		}
+		path, err := getLibraryCommandsFilePath(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if !libraryExists(args[0]) {
+			fmt.Println("library does not exist:", args[0])
+			return
+		}
+		if !askForConfirmation(fmt.Sprintf("Are you sure you want to delete library %s and all of its commands?", args[0])) {
+			fmt.Println("Aborted")
+			return
+		}
+		if err = os.Remove(path); err != nil {
+			fmt.Println("error while deleting library:", err)
+			return
+		}
+		if viper.GetString("library") == args[0] {
+			if err = saveConfigValue("library", defaultLibrary); err != nil {
+				fmt.Println("error while saving config:", err)
+			}
+		}
+		commitStoreChangeOrWarn("Delete library " + args[0])
+		fmt.Println("Library is successfully deleted")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(libraryCmd)
+	libraryCmd.AddCommand(libraryListCmd, libraryCreateCmd, libraryUseCmd, libraryDeleteCmd)
+}
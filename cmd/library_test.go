@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestCurrentLibrary(t *testing.T) {
+	defer func(flag string) { libraryFlag = flag }(libraryFlag)
+	defer viper.Set("library", nil)
+	tests := []struct {
+		flag, selected string
+		want           string
+	}{
+		{"", "", defaultLibrary},
+		{"", "work", "work"},
+		{"personal", "work", "personal"},
+	}
+	for _, test := range tests {
+		libraryFlag = test.flag
+		viper.Set("library", test.selected)
+		if library := getCurrentLibrary(); library != test.want {
+			t.Errorf("flag %q, selected %q: library is %q, want %q", test.flag, test.selected, library, test.want)
+		}
+	}
+}
+
+func TestLibraryFiles(t *testing.T) {
+	t.Setenv(homeEnvVariable, t.TempDir())
+	defer func(flag string, all bool) { libraryFlag, allLibraries = flag, all }(libraryFlag, allLibraries)
+	appDirPath, err := getAppDirPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{defaultLibrary: commandsFileName, "work": filepath.Join(librariesDirName, "work.json")} {
+		if path, _ := getLibraryCommandsFilePath(name); path != filepath.Join(appDirPath, want) {
+			t.Errorf("library %s is at %s", name, path)
+		}
+	}
+	for _, name := range []string{"", "../etc", "a/b", ".hidden"} {
+		if _, err := getLibraryCommandsFilePath(name); err == nil {
+			t.Errorf("invalid library name %q is accepted", name)
+		}
+	}
+
+	// libraries exist only once they are created
+	if err = checkLibraryExists(defaultLibrary); err != nil {
+		t.Error(err)
+	}
+	if err = checkLibraryExists("work"); err == nil {
+		t.Error("library which is not created exists")
+	}
+	for _, library := range []string{"work", "personal"} {
+		path, _ := getLibraryCommandsFilePath(library)
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = writeLibraryCommands(library, Commands{Commands: []Command{{ID: library, Command: "echo " + library}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if names, _ := getLibraryNames(); !reflect.DeepEqual(names, []string{defaultLibrary, "personal", "work"}) {
+		t.Errorf("libraries are %q", names)
+	}
+
+	libraryFlag, allLibraries = "work", false
+	if libraries, _ := getSearchedLibraries(); !reflect.DeepEqual(libraries, []string{"work"}) {
+		t.Errorf("searched libraries are %q", libraries)
+	}
+	allLibraries = true
+	if libraries, _ := getSearchedLibraries(); !reflect.DeepEqual(libraries, []string{"work", defaultLibrary, "personal"}) {
+		t.Errorf("searched libraries with --all-libraries are %q", libraries)
+	}
+	commands, err := getAllCommands()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range commands.Commands {
+		if command.ID != command.Library {
+			t.Errorf("command %s is read from library %q", command.ID, command.Library)
+		}
+	}
+
+	libraryFlag, allLibraries = "typo", false
+	if _, err = getSearchedLibraries(); err == nil {
+		t.Error("library which is not created is searched")
+	}
+}
@@ -23,10 +23,95 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
+var (
+	listFormat  string
+	listSort    string
+	listReverse bool
+	listColumns []string
+	listLimit   int
+	listOffset  int
+)
+
+// Functions that report whether command a comes before command b for each
+// supported --sort key
+var commandSorters = map[string]func(a, b Command) bool{
+	"alias": func(a, b Command) bool {
+		return strings.ToLower(a.Alias) < strings.ToLower(b.Alias)
+	},
+	"command": func(a, b Command) bool {
+		return getCommandText(a) < getCommandText(b)
+	},
+	"created": func(a, b Command) bool {
+		return a.CreatedAt.Before(b.CreatedAt)
+	},
+	// most recently used commands first
+	"last-used": func(a, b Command) bool {
+		return a.LastUsedAt.After(b.LastUsedAt)
+	},
+	// most frequently used commands first
+	"usage": func(a, b Command) bool {
+		return a.UsageCount > b.UsageCount
+	},
+}
+
+// Sorts commands by key. Empty key keeps insertion order.
+func sortCommands(commands []Command, key string, reverse bool) error {
+	if key != "" {
+		less, ok := commandSorters[key]
+		if !ok {
+			return fmt.Errorf("unknown sort key %q (use alias, created, last-used, usage or command)", key)
+		}
+		sort.SliceStable(commands, func(i, j int) bool {
+			return less(commands[i], commands[j])
+		})
+	}
+	if reverse {
+		for i, j := 0, len(commands)-1; i < j; i, j = i+1, j-1 {
+			commands[i], commands[j] = commands[j], commands[i]
+		}
+	}
+	return nil
+}
+
+// Sorts commands by key for listing. Commands scoped to the current directory
+// or repository stay first, sorted among themselves.
+func orderListedCommands(commands []Command, key string, reverse, all bool) ([]Command, error) {
+	if err := sortCommands(commands, key, reverse); err != nil {
+		return nil, err
+	}
+	return applyScopes(commands, all), nil
+}
+
+// Returns the page of commands selected by offset and limit
+func paginateCommands(commands []Command, offset, limit int) []Command {
+	if offset > len(commands) {
+		offset = len(commands)
+	}
+	commands = commands[offset:]
+	if limit > 0 && limit < len(commands) {
+		commands = commands[:limit]
+	}
+	return commands
+}
+
+// Validates column names given by --columns
+func validateColumns(columns []string) error {
+	for _, column := range columns {
+		if _, ok := commandColumnTitles[column]; !ok {
+			return fmt.Errorf("unknown column %q", column)
+		}
+	}
+	return nil
+}
+
 // listCmd represents the list command
 var listCmd = &cobra.Command{
 	Use:   "list",
@@ -40,14 +125,9 @@ var listCmd = &cobra.Command{
 			return
 		}
 
-		if !checkIfCommandsFileExists() {
-			fmt.Println(warningCommandsFileNotExist)
-			return
-		}
-
-		// get commands from commands file
+		// get commands from every layer
 		var commands *Commands
-		commands, err = getCommands()
+		commands, err = getAllCommands()
 		if err != nil {
 			fmt.Println("get commands error:", err)
 			return
@@ -56,11 +136,66 @@ var listCmd = &cobra.Command{
 			fmt.Println(warningCommandsFileNotExist)
 			return
 		}
-		printCommandsAsTable(commands)
+		if listOffset < 0 || listLimit < 0 {
+			fmt.Println("limit and offset can not be negative")
+			return
+		}
+		if err = validateColumns(listColumns); err != nil {
+			fmt.Println(err)
+			return
+		}
+		commands.Commands, err = orderListedCommands(commands.Commands, listSort, listReverse, allScopes)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		page := paginateCommands(commands.Commands, listOffset, listLimit)
+		if len(page) == 0 {
+			fmt.Println("No commands to show at this offset")
+			return
+		}
+
+		if listFormat == "" {
+			listFormat = viper.GetString("output.format")
+		}
+		if listFormat != "" {
+			err = printCommandsWithTemplate(os.Stdout, page, listFormat)
+			if err != nil {
+				fmt.Println("format error:", err)
+			}
+			return
+		}
+		columns := listColumns
+		if !cmd.Flags().Changed("columns") && hasMultipleLayers(commands.Commands) {
+			columns = append(append([]string(nil), listColumns...), "layer")
+		}
+		if !cmd.Flags().Changed("columns") && hasMultipleLibraries(commands.Commands) {
+			columns = append(append([]string(nil), columns...), "library")
+		}
+		if !cmd.Flags().Changed("columns") && hasInterpreters(commands.Commands) {
+			columns = append(append([]string(nil), columns...), "interpreter")
+		}
+		printCommandsAsTable(page, columns)
 		return
 	},
 }
 
+// Checks if any command has its own interpreter
+func hasInterpreters(commands []Command) bool {
+	for _, command := range commands {
+		if command.Interpreter != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().StringVarP(&listFormat, "format", "f", "", "Go template (or name of a template in config file) used to print each command")
+	listCmd.Flags().StringVarP(&listSort, "sort", "s", "", "sort by alias, created, last-used, usage or command, after commands scoped to here")
+	listCmd.Flags().BoolVarP(&listReverse, "reverse", "r", false, "reverse the order of commands")
+	listCmd.Flags().StringSliceVarP(&listColumns, "columns", "c", defaultCommandColumns, "columns to show (id, command, description, alias, tags, created, last-used, usage, layer, library, scope, interpreter, workdir, env)")
+	listCmd.Flags().IntVarP(&listLimit, "limit", "n", 0, "maximum number of commands to show")
+	listCmd.Flags().IntVar(&listOffset, "offset", 0, "number of commands to skip")
 }
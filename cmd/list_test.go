@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+func TestOrderListedCommandsKeepsScopedFirst(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	here := &Scope{Paths: []string{dir}}
+	elsewhere := &Scope{Paths: []string{"/nonexistent/elsewhere"}}
+	commands := []Command{
+		{ID: "a", Alias: "a", UsageCount: 9},
+		{ID: "b", Alias: "b", UsageCount: 1, Scope: here},
+		{ID: "c", Alias: "c", UsageCount: 5},
+		{ID: "d", Alias: "d", UsageCount: 7, Scope: here},
+		{ID: "e", Alias: "e", UsageCount: 8, Scope: elsewhere},
+	}
+	tests := []struct {
+		key     string
+		reverse bool
+		all     bool
+		want    []string
+	}{
+		{"", false, false, []string{"b", "d", "a", "c"}},
+		{"usage", false, false, []string{"d", "b", "a", "c"}},
+		{"usage", true, false, []string{"b", "d", "c", "a"}},
+		{"alias", true, true, []string{"d", "b", "e", "c", "a"}},
+	}
+	for _, test := range tests {
+		ordered, err := orderListedCommands(append([]Command(nil), commands...), test.key, test.reverse, test.all)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, command := range ordered {
+			ids = append(ids, command.ID)
+		}
+		if !reflect.DeepEqual(ids, test.want) {
+			t.Errorf("--sort %q reverse %v all %v: %q, want %q", test.key, test.reverse, test.all, ids, test.want)
+		}
+	}
+	if _, err := orderListedCommands(commands, "size", false, false); err == nil {
+		t.Error("unknown sort key is accepted")
+	}
+}
+
+func TestSortCommands(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC) }
+	commands := []Command{
+		{ID: "a", Alias: "beta", Command: "c", CreatedAt: day(2), LastUsedAt: day(5), UsageCount: 1},
+		{ID: "b", Alias: "Alpha", Command: "a", CreatedAt: day(3), UsageCount: 3},
+		{ID: "c", Alias: "gamma", Command: "b", CreatedAt: day(1), LastUsedAt: day(9), UsageCount: 2},
+	}
+	tests := []struct {
+		key     string
+		reverse bool
+		want    []string
+	}{
+		{"", false, []string{"a", "b", "c"}},
+		{"", true, []string{"c", "b", "a"}},
+		{"alias", false, []string{"b", "a", "c"}},
+		{"command", false, []string{"b", "c", "a"}},
+		{"created", false, []string{"c", "a", "b"}},
+		{"last-used", false, []string{"c", "a", "b"}},
+		{"usage", false, []string{"b", "c", "a"}},
+		{"usage", true, []string{"a", "c", "b"}},
+	}
+	for _, test := range tests {
+		sorted := append([]Command(nil), commands...)
+		if err := sortCommands(sorted, test.key, test.reverse); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, command := range sorted {
+			ids = append(ids, command.ID)
+		}
+		if !reflect.DeepEqual(ids, test.want) {
+			t.Errorf("--sort %q reverse %v: %q, want %q", test.key, test.reverse, ids, test.want)
+		}
+	}
+}
+
+func TestPaginateCommands(t *testing.T) {
+	commands := []Command{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	tests := []struct {
+		offset, limit int
+		want          int
+	}{
+		{0, 0, 3},
+		{1, 0, 2},
+		{0, 2, 2},
+		{2, 5, 1},
+		{5, 1, 0},
+	}
+	for _, test := range tests {
+		if page := paginateCommands(commands, test.offset, test.limit); len(page) != test.want {
+			t.Errorf("offset %d limit %d: %d commands, want %d", test.offset, test.limit, len(page), test.want)
+		}
+	}
+}
+
+func TestCommandColumns(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = true
+
+	if err := validateColumns([]string{"id", "alias", "last-used", "env"}); err != nil {
+		t.Error(err)
+	}
+	if err := validateColumns([]string{"alias", "size"}); err == nil {
+		t.Error("unknown column is accepted")
+	}
+
+	command := Command{
+		ID:         "a1",
+		Command:    "kubectl get pods --all-namespaces --output wide --watch",
+		Alias:      "pods",
+		Tags:       []string{"k8s", "ops"},
+		UsageCount: 4,
+		Env:        map[string]string{"KUBECONFIG": "~/.kube/dev"},
+		EnvFile:    ".env",
+	}
+	values := map[string]string{
+		"id":        "a1",
+		"alias":     "pods",
+		"tags":      "k8s, ops",
+		"usage":     "4",
+		"created":   "-",
+		"last-used": "-",
+		"env":       ".env KUBECONFIG=~/.kube/dev",
+	}
+	for column, want := range values {
+		if value := getCommandColumnValue(command, column); value != want {
+			t.Errorf("column %s = %q, want %q", column, value, want)
+		}
+	}
+
+	// the command column is truncated so that the table fits
+	table := renderCommandsTable([]Command{command}, []string{"alias", "command"}, 40)
+	for _, line := range strings.Split(table, "\n") {
+		if width := len([]rune(line)); width > 40 {
+			t.Errorf("line is %d wide: %q", width, line)
+		}
+	}
+	if !strings.Contains(table, "ALIAS") || !strings.Contains(table, "kubectl get") || !strings.Contains(table, "...") {
+		t.Errorf("unexpected table:\n%s", table)
+	}
+}
@@ -0,0 +1,310 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"reflect"
+)
+
+// Kinds of merge conflicts
+var (
+	conflictEditEdit   = "edit/edit"
+	conflictEditDelete = "edit/delete"
+	conflictDeleteEdit = "delete/edit"
+	conflictAddAdd     = "add/add"
+)
+
+// A change made on both sides which can not be merged automatically. Local
+// and Remote are the two candidate versions; a nil version means the command
+// is deleted on that side.
+type mergeConflict struct {
+	Kind   string
+	Fields []string
+	Local  *Command
+	Remote *Command
+}
+
+// Fields of a command that are merged separately
+var mergeableFields = []string{"command", "description", "alias", "tags", "variables", "placeholders", "scope", "interpreter", "fail_fast", "steps", "runbook", "workdir", "env", "env_file", "timeout", "retries", "retry_delay", "capture"}
+
+// Returns value of a mergeable field of command
+func getCommandField(command Command, field string) interface{} {
+	switch field {
+	case "command":
+		return command.Command
+	case "description":
+		return command.Description
+	case "alias":
+		return command.Alias
+	case "tags":
+		return normalizeStrings(command.Tags)
+	case "variables":
+		return normalizeStringMap(command.Variables)
+	case "placeholders":
+		return normalizeStrings(command.Placeholders)
+	case "scope":
+		if !isScoped(command) {
+			return (*Scope)(nil)
+		}
+		return command.Scope
+	case "interpreter":
+		return command.Interpreter
+	case "fail_fast":
+		return command.FailFast
+	case "steps":
+		if len(command.Steps) == 0 {
+			return []WorkflowStep(nil)
+		}
+		return command.Steps
+	case "runbook":
+		return command.Runbook
+	case "workdir":
+		return command.Workdir
+	case "env":
+		return normalizeStringMap(command.Env)
+	case "env_file":
+		return command.EnvFile
+	case "timeout":
+		return command.Timeout
+	case "retries":
+		return command.Retries
+	case "retry_delay":
+		return command.RetryDelay
+	case "capture":
+		return command.Capture
+	}
+	return nil
+}
+
+// Sets field of command to the value of the same field in source
+func copyCommandField(command *Command, source Command, field string) {
+	switch field {
+	case "command":
+		command.Command = source.Command
+	case "description":
+		command.Description = source.Description
+	case "alias":
+		command.Alias = source.Alias
+	case "tags":
+		command.Tags = source.Tags
+	case "variables":
+		command.Variables = source.Variables
+	case "placeholders":
+		command.Placeholders = source.Placeholders
+	case "scope":
+		command.Scope = source.Scope
+	case "interpreter":
+		command.Interpreter = source.Interpreter
+	case "fail_fast":
+		command.FailFast = source.FailFast
+	case "steps":
+		command.Steps = source.Steps
+	case "runbook":
+		command.Runbook = source.Runbook
+	case "workdir":
+		command.Workdir = source.Workdir
+	case "env":
+		command.Env = source.Env
+	case "env_file":
+		command.EnvFile = source.EnvFile
+	case "timeout":
+		command.Timeout = source.Timeout
+	case "retries":
+		command.Retries = source.Retries
+	case "retry_delay":
+		command.RetryDelay = source.RetryDelay
+	case "capture":
+		command.Capture = source.Capture
+	}
+}
+
+// Returns true if a and b have the same content. Usage statistics are not
+// compared since they change on every machine independently.
+func isSameCommand(a, b Command) bool {
+	for _, field := range mergeableFields {
+		if !reflect.DeepEqual(getCommandField(a, field), getCommandField(b, field)) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeStrings(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+func normalizeStringMap(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// Gives commands of other the IDs of the commands with the same command text
+// in reference. Commands which still have no ID get a new one.
+func alignCommandIDs(other []Command, reference []Command) {
+	ids := map[string]string{}
+	for _, command := range reference {
+		ids[command.Command] = command.ID
+	}
+	for i := range other {
+		if other[i].ID == "" {
+			other[i].ID = ids[other[i].Command]
+		}
+		if other[i].ID == "" {
+			other[i].ID = newCommandID()
+		}
+	}
+}
+
+// Combines usage statistics of two versions of the same command
+func mergeUsage(command Command, other Command) Command {
+	if other.LastUsedAt.After(command.LastUsedAt) {
+		command.LastUsedAt = other.LastUsedAt
+	}
+	if other.UsageCount > command.UsageCount {
+		command.UsageCount = other.UsageCount
+	}
+	return command
+}
+
+// Merges two versions of a command field by field. Fields changed on one
+// side only are taken from that side. Returns the merge with local values
+// and with remote values for conflicting fields, and the conflicting fields.
+func mergeCommandFields(base, local, remote Command) (Command, Command, []string) {
+	merged := mergeUsage(local, remote)
+	var conflicting []string
+	for _, field := range mergeableFields {
+		baseValue := getCommandField(base, field)
+		localValue := getCommandField(local, field)
+		remoteValue := getCommandField(remote, field)
+		switch {
+		case reflect.DeepEqual(localValue, remoteValue):
+		case reflect.DeepEqual(localValue, baseValue):
+			copyCommandField(&merged, remote, field)
+		case reflect.DeepEqual(remoteValue, baseValue):
+		default:
+			conflicting = append(conflicting, field)
+		}
+	}
+	withRemote := merged
+	for _, field := range conflicting {
+		copyCommandField(&withRemote, remote, field)
+	}
+	return merged, withRemote, conflicting
+}
+
+// Merges local and remote commands which both derive from base. Commands are
+// matched by ID and merged field by field. Unresolved conflicts keep the
+// local version, or the edited version when the other side deleted it.
+func mergeCommands(base, local, remote []Command) ([]Command, []mergeConflict) {
+	baseByID := map[string]Command{}
+	for _, command := range base {
+		baseByID[command.ID] = command
+	}
+	remoteByID := map[string]Command{}
+	for _, command := range remote {
+		remoteByID[command.ID] = command
+	}
+	localIDs := map[string]bool{}
+
+	var merged []Command
+	var conflicts []mergeConflict
+	var localAdded []Command
+	for _, localCommand := range local {
+		localIDs[localCommand.ID] = true
+		baseCommand, inBase := baseByID[localCommand.ID]
+		remoteCommand, inRemote := remoteByID[localCommand.ID]
+		switch {
+		case !inRemote && !inBase:
+			// added locally
+			merged = append(merged, localCommand)
+			localAdded = append(localAdded, localCommand)
+		case !inRemote:
+			// deleted remotely, keep it only if it was changed locally
+			if !isSameCommand(localCommand, baseCommand) {
+				kept := localCommand
+				merged = append(merged, kept)
+				conflicts = append(conflicts, mergeConflict{Kind: conflictEditDelete, Local: &kept})
+			}
+		case !inBase:
+			// added on both sides with the same ID
+			withLocal, withRemote, fields := mergeCommandFields(Command{}, localCommand, remoteCommand)
+			merged = append(merged, withLocal)
+			if len(fields) > 0 {
+				conflicts = append(conflicts, mergeConflict{Kind: conflictEditEdit, Fields: fields, Local: &withLocal, Remote: &withRemote})
+			}
+		default:
+			withLocal, withRemote, fields := mergeCommandFields(baseCommand, localCommand, remoteCommand)
+			merged = append(merged, withLocal)
+			if len(fields) > 0 {
+				conflicts = append(conflicts, mergeConflict{Kind: conflictEditEdit, Fields: fields, Local: &withLocal, Remote: &withRemote})
+			}
+		}
+	}
+	for _, remoteCommand := range remote {
+		if localIDs[remoteCommand.ID] {
+			continue
+		}
+		baseCommand, inBase := baseByID[remoteCommand.ID]
+		switch {
+		case !inBase:
+			// added remotely, check it against commands added locally
+			if conflict, duplicate := checkAddedCommand(localAdded, remoteCommand); duplicate {
+				continue
+			} else if conflict != nil {
+				conflicts = append(conflicts, *conflict)
+			}
+			merged = append(merged, remoteCommand)
+		case !isSameCommand(remoteCommand, baseCommand):
+			// deleted locally but changed remotely, keep the remote change
+			kept := remoteCommand
+			merged = append(merged, kept)
+			conflicts = append(conflicts, mergeConflict{Kind: conflictDeleteEdit, Remote: &kept})
+		}
+	}
+	return merged, conflicts
+}
+
+// Checks a remotely added command against locally added ones. Reports a
+// duplicate if the same command was added on both sides, or an add/add
+// conflict if a different command was added with the same alias.
+func checkAddedCommand(localAdded []Command, remoteCommand Command) (*mergeConflict, bool) {
+	for _, localCommand := range localAdded {
+		if isSameCommand(localCommand, remoteCommand) {
+			return nil, true
+		}
+	}
+	if remoteCommand.Alias == "" {
+		return nil, false
+	}
+	for _, localCommand := range localAdded {
+		if localCommand.Alias == remoteCommand.Alias {
+			local, remote := localCommand, remoteCommand
+			return &mergeConflict{Kind: conflictAddAdd, Fields: []string{"alias"}, Local: &local, Remote: &remote}, false
+		}
+	}
+	return nil, false
+}
@@ -27,10 +27,21 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+var newScopePaths, newScopeRemotes []string
+var newScopeHere bool
+var newInterpreter, newWorkdir, newEnvFile string
+var newEnv, newPlaceholders []string
+var newTimeout, newRetryDelay time.Duration
+var newRetries int
+var newCapture bool
+var newScript, newWorkflow, newFailFast bool
+
 // newCmd represents the new command
 var newCmd = &cobra.Command{
 	Use:   "new",
@@ -42,20 +53,52 @@ var newCmd = &cobra.Command{
 			initCmd.Run(cmd, args)
 			return
 		}
+		if err = checkLibraryExists(getCurrentLibrary()); err != nil {
+			fmt.Println(err)
+			return
+		}
 		// get command and description
-		var commandInput, descriptionInput, aliasInput string
+		var commandInput string
+		var steps []WorkflowStep
 		scanner := bufio.NewScanner(os.Stdin)
-		fmt.Printf("Command: ")
-		scanner.Scan()
-		commandInput = scanner.Text()
-
-		fmt.Printf("Description: ")
-		scanner.Scan()
-		descriptionInput = scanner.Text()
+		if newWorkflow {
+			stepsInput, err := readScript(workflowTemplate)
+			if err == nil {
+				steps, err = parseWorkflowSteps(stepsInput)
+			}
+			if err != nil {
+				fmt.Println("error while reading workflow:", err)
+				return
+			}
+		} else if newScript {
+			commandInput, err = readScript("")
+			if err != nil {
+				fmt.Println("error while reading script:", err)
+				return
+			}
+		} else {
+			fmt.Printf("Command: ")
+			scanner.Scan()
+			commandInput = scanner.Text()
+		}
+		// a script read from stdin leaves nothing to prompt from
+		prompt := !(newScript || newWorkflow) || isStdinTerminal()
+		descriptionInput := promptUnlessSet(cmd, scanner, prompt, "description", "Description: ")
+		aliasInput := promptUnlessSet(cmd, scanner, prompt, "alias", "Alias: ")
+		tagsInput := promptUnlessSet(cmd, scanner, prompt, "tags", "Tags (comma separated): ")
 
-		fmt.Printf("Alias: ")
-		scanner.Scan()
-		aliasInput = scanner.Text()
+		env, err := parseEnvPairs(newEnv)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		names := getPlaceholderNames(commandInput)
+		for _, name := range newPlaceholders {
+			if !isStringInSlice(name, names) {
+				fmt.Printf("no placeholder <%s> in command\n", name)
+				return
+			}
+		}
 
 		// create commands file if not exist
 		if !checkIfCommandsFileExists() {
@@ -68,14 +111,32 @@ var newCmd = &cobra.Command{
 
 		// write command to file as json
 		// check if there is a record on file.
+		newCommand := Command{
+			ID:           newCommandID(),
+			Command:      commandInput,
+			Description:  descriptionInput,
+			Alias:        aliasInput,
+			Tags:         parseTags(tagsInput),
+			Placeholders: newPlaceholders,
+			CreatedAt:    time.Now(),
+			Scope:        getNewCommandScope(),
+			Interpreter:  newInterpreter,
+			FailFast:     newFailFast,
+			Steps:        steps,
+			Workdir:      newWorkdir,
+			Env:          env,
+			EnvFile:      newEnvFile,
+			Retries:      newRetries,
+			Capture:      newCapture,
+		}
+		if newTimeout > 0 {
+			newCommand.Timeout = newTimeout.String()
+		}
+		if newRetryDelay > 0 {
+			newCommand.RetryDelay = newRetryDelay.String()
+		}
 		newCommands := Commands{
-			Commands: []Command{
-				{
-					Command:     commandInput,
-					Description: descriptionInput,
-					Alias:       aliasInput,
-				},
-			},
+			Commands: []Command{newCommand},
 		}
 		commandsFilePath, err := getCommandsFilePath()
 		if err != nil {
@@ -94,6 +155,7 @@ var newCmd = &cobra.Command{
 				fmt.Println(err)
 				return
 			}
+			commitStoreChangeOrWarn("Add " + describeCommand(newCommand))
 			return
 		}
 
@@ -105,7 +167,6 @@ var newCmd = &cobra.Command{
 			fmt.Println("unmarshall error:", err)
 			return
 		}
-		newCommand := Command{Command: commandInput, Description: descriptionInput, Alias: aliasInput}
 		existingCommands.Commands = append(existingCommands.Commands, newCommand)
 
 		err = writeCommandsToFile(existingCommands)
@@ -113,12 +174,95 @@ var newCmd = &cobra.Command{
 			fmt.Println(err)
 			return
 		}
+		commitStoreChangeOrWarn("Add " + describeCommand(newCommand))
 		fmt.Println("Command is successfully saved")
 		return
 
 	},
 }
 
+// Returns value of flag if it is given, otherwise reads it from stdin
+// after printing label
+func promptUnlessSet(cmd *cobra.Command, scanner *bufio.Scanner, prompt bool, flag, label string) string {
+	value, _ := cmd.Flags().GetString(flag)
+	if cmd.Flags().Changed(flag) || !prompt {
+		return value
+	}
+	fmt.Print(label)
+	scanner.Scan()
+	return scanner.Text()
+}
+
+// Reads a multi-line script from stdin, or from the configured editor when
+// stdin is a terminal. The editor starts with template.
+func readScript(template string) (string, error) {
+	var content []byte
+	var err error
+	if isStdinTerminal() {
+		file, err := ioutil.TempFile("", "katip-new-")
+		if err != nil {
+			return "", err
+		}
+		_, err = file.WriteString(template)
+		file.Close()
+		defer os.Remove(file.Name())
+		if err != nil {
+			return "", err
+		}
+		if err = openInEditor(file.Name()); err != nil {
+			return "", err
+		}
+		content, err = ioutil.ReadFile(file.Name())
+	} else {
+		content, err = ioutil.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return "", err
+	}
+	// editors and heredocs end the last line with a newline
+	script := strings.TrimRight(string(content), "\r\n")
+	if strings.TrimSpace(script) == "" {
+		return "", fmt.Errorf("script is empty")
+	}
+	return script, nil
+}
+
+// Builds the scope of a new command from flags. --here scopes it to the
+// remote of the current git repository, or to the current directory.
+func getNewCommandScope() *Scope {
+	scope := Scope{Paths: newScopePaths, Remotes: newScopeRemotes}
+	if newScopeHere {
+		context := getScopeContext()
+		if len(context.remotes) > 0 {
+			scope.Remotes = append(scope.Remotes, context.remotes[0])
+		} else if context.dir != "" {
+			scope.Paths = append(scope.Paths, context.dir)
+		}
+	}
+	if len(scope.Paths) == 0 && len(scope.Remotes) == 0 {
+		return nil
+	}
+	return &scope
+}
+
 func init() {
 	rootCmd.AddCommand(newCmd)
+	newCmd.Flags().StringSliceVar(&newScopePaths, "path", nil, "scope command to directories matching path glob")
+	newCmd.Flags().StringSliceVar(&newScopeRemotes, "remote", nil, "scope command to git repositories matching remote URL")
+	newCmd.Flags().BoolVar(&newScript, "script", false, "save a multi-line script read from stdin or written in your editor")
+	newCmd.Flags().BoolVar(&newWorkflow, "workflow", false, "save a workflow of saved commands, with steps written in YAML")
+	newCmd.Flags().BoolVar(&newFailFast, "fail-fast", false, "stop the script at its first failing line when it is run")
+	newCmd.Flags().StringP("description", "d", "", "description of command")
+	newCmd.Flags().StringP("alias", "a", "", "alias of command")
+	newCmd.Flags().StringP("tags", "t", "", "comma separated tags of command")
+	newCmd.Flags().StringVar(&newInterpreter, "interpreter", "", "interpreter to run command with, such as zsh, python3 or \"ruby -e\" (default is the configured shell)")
+	newCmd.Flags().StringVar(&newWorkdir, "workdir", "", "directory to run command in, ~ and $VARIABLES are expanded")
+	newCmd.Flags().StringArrayVar(&newEnv, "env", nil, "environment variable to run command with, as KEY=VALUE")
+	newCmd.Flags().StringSliceVar(&newPlaceholders, "placeholder", nil, "name of a <placeholder> in command asked for when it is run")
+	newCmd.Flags().StringVar(&newEnvFile, "env-file", "", "dotenv file to read environment variables from")
+	newCmd.Flags().DurationVar(&newTimeout, "timeout", 0, "default timeout of command")
+	newCmd.Flags().IntVar(&newRetries, "retries", 0, "default number of retries of command")
+	newCmd.Flags().DurationVar(&newRetryDelay, "retry-delay", 0, "default time to wait between retries of command")
+	newCmd.Flags().BoolVar(&newCapture, "capture", false, "always save the output of command, to be shown with \"katip runs show\"")
+	newCmd.Flags().BoolVar(&newScopeHere, "here", false, "scope command to current git repository or directory")
 }
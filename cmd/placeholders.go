@@ -0,0 +1,193 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Placeholders anywhere in a command, capturing name and default value
+var commandPlaceholderPattern = regexp.MustCompile(`<([A-Za-z_][A-Za-z0-9_.-]*)(=[^<>\n]*)?>`)
+
+// Time a variable command gets to list suggestions for a placeholder
+const suggestionTimeout = 10 * time.Second
+
+// Suggestions shown for a placeholder at most
+const maxSuggestions = 20
+
+// Returns names of the placeholders in text, in order of appearance
+func getPlaceholderNames(text string) []string {
+	var names []string
+	for _, match := range commandPlaceholderPattern.FindAllStringSubmatch(text, -1) {
+		if !isStringInSlice(match[1], names) {
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// Replaces placeholders in text with values. Only placeholders declared in
+// names or given a value are replaced, other text in angle brackets is kept
+// as it is. A declared placeholder without a value gets its default, or is
+// asked for when ask is set. Commands in variables, keyed by placeholder
+// name, list the suggestions offered when asking.
+func renderPlaceholders(text string, names []string, values, variables map[string]string, ask bool) (string, error) {
+	resolved, err := resolvePlaceholders(text, names, values, variables, ask)
+	if err != nil {
+		return "", err
+	}
+	return replacePlaceholders(text, resolved), nil
+}
+
+// Returns values of the placeholders in text, taken from values, or for the
+// ones declared in names, their defaults or asked for when ask is set
+func resolvePlaceholders(text string, names []string, values, variables map[string]string, ask bool) (map[string]string, error) {
+	resolved := map[string]string{}
+	for _, match := range commandPlaceholderPattern.FindAllStringSubmatch(text, -1) {
+		name := match[1]
+		if _, ok := resolved[name]; ok {
+			continue
+		}
+		if value, ok := values[name]; ok {
+			resolved[name] = value
+			continue
+		}
+		if !isStringInSlice(name, names) {
+			continue
+		}
+		defaultValue, hasDefault := strings.TrimPrefix(match[2], "="), match[2] != ""
+		if !ask {
+			if hasDefault {
+				resolved[name] = defaultValue
+			}
+			continue
+		}
+		var suggestions []string
+		if variable, ok := variables[name]; ok {
+			// variables may come from a project file, show what is run
+			fmt.Fprintf(os.Stderr, "%s: suggestions from %s\n", name, variable)
+			var err error
+			if suggestions, err = getPlaceholderSuggestions(variable, resolved); err != nil {
+				fmt.Fprintf(os.Stderr, "warning : no suggestions for %s: %v\n", name, err)
+			}
+		}
+		if !hasDefault && len(suggestions) > 0 {
+			defaultValue, hasDefault = suggestions[0], true
+		}
+		// prompts go to stderr, leaving stdout to the command
+		for i, suggestion := range suggestions {
+			fmt.Fprintf(os.Stderr, "  %d) %s\n", i+1, suggestion)
+		}
+		if hasDefault {
+			fmt.Fprintf(os.Stderr, "%s [%s]: ", name, defaultValue)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: ", name)
+		}
+		value, err := readLine()
+		if err != nil {
+			return nil, err
+		}
+		if value == "" && hasDefault {
+			value = defaultValue
+		} else if i, err := strconv.Atoi(value); err == nil && i >= 1 && i <= len(suggestions) {
+			value = suggestions[i-1]
+		}
+		resolved[name] = value
+	}
+	return resolved, nil
+}
+
+// Returns the lines printed by a navi style variable command, which suggest
+// values for a placeholder. Values resolved so far are passed to it as
+// environment variables, so it can depend on earlier placeholders.
+func getPlaceholderSuggestions(command string, resolved map[string]string) ([]string, error) {
+	// options after "---" are for navi's fzf and are not supported
+	if index := strings.Index(command, "---"); index >= 0 {
+		command = command[:index]
+	}
+	process, cleanup, err := buildRunCommand(Command{Command: command}, false)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	if process.Env == nil {
+		process.Env = os.Environ()
+	}
+	for name, value := range resolved {
+		process.Env = append(process.Env, name+"="+value)
+	}
+	var output bytes.Buffer
+	process.Stdout = &output
+	if err := runProcess(process, suggestionTimeout); err != nil {
+		return nil, err
+	}
+	var suggestions []string
+	for _, line := range strings.Split(output.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" && len(suggestions) < maxSuggestions {
+			suggestions = append(suggestions, line)
+		}
+	}
+	return suggestions, nil
+}
+
+// Replaces placeholders in text which have a value in resolved
+func replacePlaceholders(text string, resolved map[string]string) string {
+	return commandPlaceholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := commandPlaceholderPattern.FindStringSubmatch(placeholder)[1]
+		if value, ok := resolved[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+// Reads a line from stdin without buffering, so that input after the line
+// is left for the next reader
+func readLine() (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF {
+			if len(line) == 0 {
+				return "", fmt.Errorf("no input for placeholder")
+			}
+			break
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimRight(string(line), "\r"), nil
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetPlaceholderSuggestions(t *testing.T) {
+	t.Setenv(homeEnvVariable, t.TempDir())
+
+	// earlier values are visible to the command, navi options are dropped
+	suggestions, err := getPlaceholderSuggestions(`printf '%s-a\n\n%s-b\n' "$env" "$env" --- --column 1`, map[string]string{"env": "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"prod-a", "prod-b"}; !reflect.DeepEqual(suggestions, want) {
+		t.Errorf("suggestions = %q, want %q", suggestions, want)
+	}
+
+	if _, err := getPlaceholderSuggestions("exit 3", nil); err == nil {
+		t.Error("failing command gave no error")
+	}
+}
+
+func TestRenderPlaceholders(t *testing.T) {
+	tests := []struct {
+		text   string
+		names  []string
+		values map[string]string
+		input  string
+		want   string
+	}{
+		// text in angle brackets is not a placeholder unless declared
+		{"cat <<EOF > <file>", nil, nil, "", "cat <<EOF > <file>"},
+		{"echo '<b>bold</b>'", nil, nil, "", "echo '<b>bold</b>'"},
+		{"cp <src> <dst>", []string{"src"}, nil, "a.txt\n", "cp a.txt <dst>"},
+		{"kubectl logs <pod> -n <namespace=default>", []string{"pod", "namespace"}, nil, "web\n\n", "kubectl logs web -n default"},
+		// given values fill placeholders which are not declared
+		{"git tag <version> <prev.output>", nil, map[string]string{"prev.output": "abc"}, "", "git tag <version> abc"},
+		{"echo <name>", []string{"name"}, map[string]string{"name": "given"}, "", "echo given"},
+	}
+	for _, test := range tests {
+		setStdin(t, test.input)
+		rendered, err := renderPlaceholders(test.text, test.names, test.values, nil, true)
+		if err != nil {
+			t.Errorf("renderPlaceholders(%q): %v", test.text, err)
+			continue
+		}
+		if rendered != test.want {
+			t.Errorf("renderPlaceholders(%q) = %q, want %q", test.text, rendered, test.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A command which running a saved command would run, with its name
+type previewedCommand struct {
+	name    string
+	command Command
+}
+
+// Returns the commands running command would run, with placeholders filled.
+// Nothing is run, not even the commands suggesting placeholder values. Steps
+// of a workflow keep placeholders of outputs, which are only known at run
+// time.
+func getPreviewedCommands(command Command) ([]previewedCommand, error) {
+	if command.Runbook != "" {
+		var previewed []previewedCommand
+		_, steps := parseRunbook(command.Runbook)
+		for _, step := range steps {
+			if step.Code == "" {
+				continue
+			}
+			rendered, err := renderPlaceholders(step.Code, command.Placeholders, nil, nil, true)
+			if err != nil {
+				return nil, err
+			}
+			previewed = append(previewed, previewedCommand{
+				name:    fmt.Sprintf("step %d", len(previewed)+1),
+				command: Command{Command: rendered, Interpreter: step.Interpreter},
+			})
+		}
+		return previewed, nil
+	}
+	if isWorkflow(command) {
+		commands, err := getAllCommands()
+		if err != nil {
+			return nil, err
+		}
+		var previewed []previewedCommand
+		outputs := getSymbolicOutputs(command)
+		for _, step := range command.Steps {
+			stepCommand, err := resolveWorkflowStep(commands.Commands, step, outputs, false)
+			if err != nil {
+				return nil, err
+			}
+			previewed = append(previewed, previewedCommand{getStepName(step), stepCommand})
+		}
+		return previewed, nil
+	}
+	// variable commands are not run for suggestions, nothing runs in a preview
+	rendered, err := renderPlaceholders(command.Command, command.Placeholders, nil, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	command.Command = rendered
+	return []previewedCommand{{name: describeCommand(command), command: command}}, nil
+}
+
+// Prints what running command would run. With --print only the rendered
+// commands are printed, otherwise how each of them would be run.
+func previewSavedCommand(command Command) error {
+	previewed, err := getPreviewedCommands(command)
+	if err != nil {
+		return err
+	}
+	for i, p := range previewed {
+		if runPrint {
+			fmt.Println(p.command.Command)
+			continue
+		}
+		if len(previewed) > 1 {
+			fmt.Printf("\n[%d/%d] %s\n", i+1, len(previewed), p.name)
+		}
+		plan, err := planRun(p.command, runFailFast || p.command.FailFast)
+		if err != nil {
+			return err
+		}
+		printRunPlan(plan)
+	}
+	return nil
+}
+
+// Prints argv, working directory, environment and script of plan
+func printRunPlan(plan runPlan) {
+	var args []string
+	for _, arg := range plan.Args {
+		if arg == scriptFileArg {
+			args = append(args, arg)
+			continue
+		}
+		args = append(args, shellQuote(arg))
+	}
+	fmt.Println("argv:    " + strings.Join(args, " "))
+	fmt.Println("workdir: " + plan.Dir)
+	if len(plan.Env) == 0 {
+		fmt.Println("env:     (no overrides)")
+	} else {
+		fmt.Println("env:")
+		for _, variable := range plan.Env {
+			fmt.Println("    " + variable)
+		}
+	}
+	fmt.Println("script:")
+	for _, line := range strings.Split(plan.Script, "\n") {
+		fmt.Println("    " + line)
+	}
+}
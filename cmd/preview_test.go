@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// Makes input the stdin of the test, for placeholders which are asked for
+func setStdin(t *testing.T, input string) {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = writer.WriteString(input); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+	stdin := os.Stdin
+	os.Stdin = reader
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		reader.Close()
+	})
+}
+
+// Returns what f prints to stdout
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	f()
+	os.Stdout = stdout
+	writer.Close()
+	out, err := ioutil.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestPreviewRunsNothing(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(homeEnvVariable, home)
+	marker := filepath.Join(home, "side-effect")
+	command := Command{
+		ID:           "a",
+		Alias:        "greet",
+		Command:      "echo hello <who> > " + marker,
+		Variables:    map[string]string{"who": "touch " + marker + "; echo one"},
+		Placeholders: []string{"who"},
+	}
+	workflow := Command{ID: "b", Steps: []WorkflowStep{{Command: "greet"}}}
+	path, err := getLibraryCommandsFilePath(defaultLibrary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeLibraryCommands(defaultLibrary, Commands{Commands: []Command{command, workflow}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, print := range []bool{false, true} {
+		runPrint = print
+		for _, previewed := range []Command{command, workflow} {
+			setStdin(t, "world\n")
+			if err := previewSavedCommand(previewed); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(marker); !os.IsNotExist(err) {
+				t.Fatalf("previewing %s ran a command", previewed.ID)
+			}
+		}
+	}
+	runPrint = false
+}
+
+func TestGetPreviewedCommands(t *testing.T) {
+	t.Setenv(homeEnvVariable, t.TempDir())
+	if err := createCommandsFile(); err != nil {
+		t.Fatal(err)
+	}
+	saved := []Command{
+		{Alias: "version", Command: "git describe"},
+		{Alias: "tag", Command: "git tag <tag> <ref=HEAD>", Placeholders: []string{"tag", "ref"}},
+	}
+	if err := writeCommandsToFile(Commands{Commands: saved}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		command Command
+		input   string
+		want    []string // name, command and interpreter of each
+	}{
+		{
+			command: Command{Command: "ssh <host> <port=22>", Placeholders: []string{"host", "port"}},
+			input:   "prod\n\n",
+			want:    []string{"ssh prod 22", "ssh prod 22", ""},
+		},
+		{
+			command: Command{Alias: "echo", Command: "echo <undeclared>"},
+			want:    []string{"echo", "echo <undeclared>", ""},
+		},
+		{
+			command: Command{Runbook: "# Book\n\n```bash\nls <dir>\n```\n\n```sh\npwd\n```\n", Placeholders: []string{"dir"}},
+			input:   "/tmp\n",
+			want:    []string{"step 1", "ls /tmp", "bash", "step 2", "pwd", "sh"},
+		},
+		{
+			command: Command{Steps: []WorkflowStep{{Command: "version"}, {Command: "tag", Bindings: map[string]string{"tag": "v<prev.output>", "ref": "main"}}}},
+			want:    []string{"version", "git describe", "", "tag", "git tag v<prev.output> main", ""},
+		},
+	}
+	for _, test := range tests {
+		setStdin(t, test.input)
+		previewed, err := getPreviewedCommands(test.command)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range previewed {
+			got = append(got, p.name, p.command.Command, p.command.Interpreter)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("getPreviewedCommands(%q) = %q, want %q", getCommandText(test.command), got, test.want)
+		}
+	}
+}
+
+func TestPrintRunPlan(t *testing.T) {
+	tests := []struct {
+		plan runPlan
+		want string
+	}{
+		{
+			plan: runPlan{Args: []string{"bash", "-c", "echo hi there"}, Script: "echo hi there", Dir: "/tmp"},
+			want: "argv:    bash -c 'echo hi there'\nworkdir: /tmp\nenv:     (no overrides)\nscript:\n    echo hi there\n",
+		},
+		{
+			plan: runPlan{Args: []string{"python3", scriptFileArg}, Script: "import os\nprint(os.getcwd())", Dir: "/srv", Env: []string{"A=1", "B=two words"}},
+			want: "argv:    python3 <script file>\nworkdir: /srv\nenv:\n    A=1\n    B=two words\nscript:\n    import os\n    print(os.getcwd())\n",
+		},
+	}
+	for _, test := range tests {
+		if out := captureStdout(t, func() { printRunPlan(test.plan) }); out != test.want {
+			t.Errorf("printRunPlan(%+v) printed %q, want %q", test.plan, out, test.want)
+		}
+	}
+}
+
+func TestPlanRunWithWorkdirAndEnv(t *testing.T) {
+	viper.Set("shell", "sh")
+	defer viper.Set("shell", nil)
+	dir := t.TempDir()
+
+	command := Command{Command: "cd <dir>\nls", Workdir: dir, Env: map[string]string{"DEBUG": "1"}, FailFast: true}
+	plan, err := planRun(command, command.FailFast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := runPlan{Args: []string{"sh", scriptFileArg}, Script: "set -e\ncd <dir>\nls", Dir: dir, Env: []string{"DEBUG=1"}}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("planRun() = %+v, want %+v", plan, want)
+	}
+}
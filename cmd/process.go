@@ -0,0 +1,218 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// Time a process has to exit after it is asked to, before it is killed
+var killGracePeriod = 5 * time.Second
+
+// Flags of the run command, set once it is initialized
+var runFlags *pflag.FlagSet
+
+// Limits of a run, from run flags or the defaults of the command
+type runOptions struct {
+	timeout    time.Duration
+	retries    int
+	retryDelay time.Duration
+	failFast   bool
+}
+
+// Reason a process was killed by katip
+type killedError struct {
+	reason      string
+	interrupted bool
+}
+
+func (e *killedError) Error() string {
+	return "killed: " + e.reason
+}
+
+// Checks if err means the run was interrupted, after which it is not retried.
+// Interrupts from the terminal go straight to a command in its foreground,
+// which then dies of SIGINT.
+func isInterrupted(err error) bool {
+	var killed *killedError
+	if errors.As(err, &killed) {
+		return killed.interrupted
+	}
+	status, ok := getWaitStatus(err)
+	return ok && status.Signaled() && status.Signal() == syscall.SIGINT
+}
+
+// Returns how the process which failed with err exited
+func getWaitStatus(err error) (syscall.WaitStatus, bool) {
+	var status syscall.WaitStatus
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return status, false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return status, ok
+}
+
+// Returns limits of running command. Flags given to run override the
+// defaults saved with the command.
+func getRunOptions(command Command) (runOptions, error) {
+	options := runOptions{retries: command.Retries, retryDelay: time.Second, failFast: command.FailFast}
+	var err error
+	if command.Timeout != "" {
+		if options.timeout, err = time.ParseDuration(command.Timeout); err != nil {
+			return options, fmt.Errorf("invalid timeout of command: %v", err)
+		}
+	}
+	if command.RetryDelay != "" {
+		if options.retryDelay, err = time.ParseDuration(command.RetryDelay); err != nil {
+			return options, fmt.Errorf("invalid retry delay of command: %v", err)
+		}
+	}
+	if runFlags == nil {
+		return options, nil
+	}
+	if runFlags.Changed("timeout") {
+		options.timeout = runTimeout
+	}
+	if runFlags.Changed("retries") {
+		options.retries = runRetries
+	}
+	if runFlags.Changed("retry-delay") {
+		options.retryDelay = runRetryDelay
+	}
+	options.failFast = options.failFast || runFailFast
+	return options, nil
+}
+
+// Runs command, retrying it when it fails, and reports which attempt
+// succeeded. Its output is written to stdout and stderr.
+func runWithRetries(command Command, options runOptions, stdout, stderr io.Writer) error {
+	attempts := options.retries + 1
+	for attempt := 1; ; attempt++ {
+		err := runOnce(command, options, stdout, stderr)
+		if err == nil {
+			if attempt > 1 {
+				fmt.Fprintf(os.Stderr, "succeeded on attempt %d of %d\n", attempt, attempts)
+			}
+			return nil
+		}
+		if attempt >= attempts || isInterrupted(err) {
+			if attempts > 1 {
+				return fmt.Errorf("attempt %d of %d failed: %w", attempt, attempts, err)
+			}
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "attempt %d of %d failed: %v, retrying in %s\n", attempt, attempts, err, options.retryDelay)
+		time.Sleep(options.retryDelay)
+	}
+}
+
+// Runs command once, within its timeout
+func runOnce(command Command, options runOptions, stdout, stderr io.Writer) error {
+	process, cleanup, err := buildRunCommand(command, options.failFast)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	process.Stdin = os.Stdin
+	process.Stdout = stdout
+	process.Stderr = stderr
+	// processes left running after the command exits or is killed, holding
+	// its output open, can not keep katip waiting
+	process.WaitDelay = killGracePeriod
+	restoreTerminal := setProcessGroup(process)
+	defer restoreTerminal()
+	return runProcess(process, options.timeout)
+}
+
+// Returns the conventional name of the signals katip passes on
+func signalName(sig os.Signal) string {
+	switch sig {
+	case os.Interrupt:
+		return "SIGINT"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	}
+	return sig.String()
+}
+
+// Runs process, killing it after timeout if it is greater than zero.
+// SIGINT and SIGTERM katip gets are passed on to the process group of the
+// process.
+func runProcess(process *exec.Cmd, timeout time.Duration) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	if err := process.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- process.Wait()
+	}()
+
+	var timeoutC, killC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+	var killed *killedError
+	stop := func(sig os.Signal, reason string, interrupted bool) {
+		if killed == nil {
+			killed = &killedError{reason, interrupted}
+			killC = time.After(killGracePeriod)
+		}
+		signalProcessGroup(process, sig)
+	}
+	for {
+		select {
+		case err := <-done:
+			if killed != nil {
+				// processes left in the group, such as background jobs which
+				// ignore SIGINT, are stopped too
+				signalProcessGroup(process, syscall.SIGTERM)
+				return killed
+			}
+			if errors.Is(err, exec.ErrWaitDelay) {
+				// the command itself succeeded
+				return nil
+			}
+			return err
+		case sig := <-signals:
+			stop(sig, "interrupted by "+signalName(sig), true)
+		case <-timeoutC:
+			stop(syscall.SIGTERM, fmt.Sprintf("timed out after %s", timeout), false)
+		case <-killC:
+			signalProcessGroup(process, os.Kill)
+		}
+	}
+}
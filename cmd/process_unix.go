@@ -0,0 +1,93 @@
+//go:build !windows
+// +build !windows
+
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// Starts the process in a process group of its own, so that signals reach
+// every process it starts. When katip is in the foreground of a terminal,
+// the group is made the foreground group for the process to read from the
+// terminal and get its interrupts. The returned function gives the terminal
+// back to katip once the process exits.
+func setProcessGroup(process *exec.Cmd) func() {
+	attr := &syscall.SysProcAttr{Setpgid: true}
+	process.SysProcAttr = attr
+	stdin, ok := process.Stdin.(*os.File)
+	if !ok || !isStdinTerminal() || stdin != os.Stdin {
+		return func() {}
+	}
+	fd := int(stdin.Fd())
+	if pgrp, err := tcgetpgrp(fd); err != nil || pgrp != syscall.Getpgrp() {
+		return func() {}
+	}
+	// the terminal is stdin of the process
+	attr.Foreground = true
+	attr.Ctty = 0
+	return func() {
+		// katip is in the background until the terminal is taken back,
+		// which would stop it without ignoring SIGTTOU
+		signal.Ignore(syscall.SIGTTOU)
+		defer signal.Reset(syscall.SIGTTOU)
+		tcsetpgrp(fd, syscall.Getpgrp())
+	}
+}
+
+// Returns the foreground process group of terminal fd
+func tcgetpgrp(fd int) (int, error) {
+	var pgrp int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgrp))); errno != 0 {
+		return 0, errno
+	}
+	return int(pgrp), nil
+}
+
+// Makes pgrp the foreground process group of terminal fd
+func tcsetpgrp(fd int, pgrp int) error {
+	value := int32(pgrp)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&value))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Sends sig to the process group of the process, or to the process alone if
+// it has no group of its own
+func signalProcessGroup(process *exec.Cmd, sig os.Signal) error {
+	signal, ok := sig.(syscall.Signal)
+	if !ok {
+		signal = syscall.SIGKILL
+	}
+	if process.SysProcAttr != nil && process.SysProcAttr.Setpgid {
+		return syscall.Kill(-process.Process.Pid, signal)
+	}
+	return process.Process.Signal(signal)
+}
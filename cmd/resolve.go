@@ -0,0 +1,403 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+var (
+	conflictsFileName = "conflicts.txt"
+	conflictMode      string
+)
+
+// Ways of resolving merge conflicts
+var (
+	conflictModeAsk    = "ask"
+	conflictModeFile   = "file"
+	conflictModeLocal  = "local"
+	conflictModeRemote = "remote"
+)
+
+// Returns path of the file holding unresolved merge conflicts of library.
+// Each library has its own, so that conflicts are resolved in the library
+// they come from.
+func getConflictsFilePath(library string) (string, error) {
+	stateDirPath, err := getStateDirPath()
+	if err != nil {
+		return "", err
+	}
+	name := conflictsFileName
+	if library != defaultLibrary {
+		name = strings.TrimSuffix(name, ".txt") + "-" + library + ".txt"
+	}
+	return filepath.Join(stateDirPath, name), nil
+}
+
+// Returns an error if any of libraries has conflicts which are not resolved
+// yet. Sync refuses to run then, its conflicts would replace them.
+func checkConflictsResolved(libraries []string) error {
+	for _, library := range libraries {
+		conflictsFilePath, err := getConflictsFilePath(library)
+		if err != nil {
+			return err
+		}
+		if _, err = os.Stat(conflictsFilePath); err == nil {
+			return fmt.Errorf("unresolved conflicts in %s, resolve them and run 'katip resolve' first", conflictsFilePath)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns IDs of the commands involved in conflict
+func conflictIDs(conflict mergeConflict) []string {
+	var ids []string
+	for _, command := range []*Command{conflict.Local, conflict.Remote} {
+		if command != nil && !isStringInSlice(command.ID, ids) {
+			ids = append(ids, command.ID)
+		}
+	}
+	return ids
+}
+
+// Replaces the commands involved in conflict with replacements. Replacements
+// take the position of the first replaced command.
+func replaceConflictingCommands(commands []Command, ids []string, replacements []Command) []Command {
+	var result []Command
+	inserted := false
+	usedIDs := map[string]bool{}
+	for _, command := range commands {
+		if !isStringInSlice(command.ID, ids) {
+			usedIDs[command.ID] = true
+		}
+	}
+	insert := func() {
+		for _, replacement := range replacements {
+			if replacement.ID == "" || usedIDs[replacement.ID] {
+				replacement.ID = newCommandID()
+			}
+			usedIDs[replacement.ID] = true
+			result = append(result, replacement)
+		}
+		inserted = true
+	}
+	for _, command := range commands {
+		if isStringInSlice(command.ID, ids) {
+			if !inserted {
+				insert()
+			}
+			continue
+		}
+		result = append(result, command)
+	}
+	if !inserted {
+		insert()
+	}
+	return result
+}
+
+// Applies choice ("local", "remote" or "both") to the merged commands
+func applyConflictChoice(merged []Command, conflict mergeConflict, choice string) []Command {
+	var replacements []Command
+	if (choice == conflictModeLocal || choice == "both") && conflict.Local != nil {
+		replacements = append(replacements, *conflict.Local)
+	}
+	if (choice == conflictModeRemote || choice == "both") && conflict.Remote != nil {
+		remote := *conflict.Remote
+		if choice == "both" && conflict.Local != nil && remote.Alias == conflict.Local.Alias && remote.Alias != "" {
+			// keep aliases unique
+			remote.Alias += "-remote"
+		}
+		replacements = append(replacements, remote)
+	}
+	return replaceConflictingCommands(merged, conflictIDs(conflict), replacements)
+}
+
+// Describes a version of a conflicting command
+func describeConflictSide(command *Command) string {
+	if command == nil {
+		return "(deleted)"
+	}
+	return formatCommandLine(*command)
+}
+
+// Prints both sides of conflict
+func printConflict(conflict mergeConflict) {
+	fmt.Printf("\nConflict (%s)", conflict.Kind)
+	if len(conflict.Fields) > 0 {
+		fmt.Printf(" on %s", strings.Join(conflict.Fields, ", "))
+	}
+	fmt.Println()
+	fmt.Println("  local : " + describeConflictSide(conflict.Local))
+	fmt.Println("  remote: " + describeConflictSide(conflict.Remote))
+}
+
+// Asks user how to resolve each conflict. Conflicts which are skipped are
+// returned to be written to the conflicts file.
+func resolveConflictsInteractively(merged []Command, conflicts []mergeConflict) ([]Command, []mergeConflict) {
+	var skipped []mergeConflict
+	scanner := bufio.NewScanner(os.Stdin)
+	for _, conflict := range conflicts {
+		printConflict(conflict)
+		for {
+			fmt.Printf("Keep [l]ocal, [r]emote, [b]oth or [s]kip: ")
+			if !scanner.Scan() {
+				skipped = append(skipped, conflict)
+				break
+			}
+			choice := ""
+			switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+			case "l", "local":
+				choice = conflictModeLocal
+			case "r", "remote":
+				choice = conflictModeRemote
+			case "b", "both":
+				choice = "both"
+			case "s", "skip":
+				skipped = append(skipped, conflict)
+			default:
+				fmt.Println("invalid input.")
+				continue
+			}
+			if choice != "" {
+				merged = applyConflictChoice(merged, conflict, choice)
+			}
+			break
+		}
+	}
+	return merged, skipped
+}
+
+// Resolves conflicts of a merge of library according to mode and returns the
+// final commands. In file mode, or for conflicts skipped interactively,
+// merged commands keep the default version and conflicts are written to the
+// conflicts file of library to be resolved later by "katip resolve".
+func resolveConflicts(merged []Command, conflicts []mergeConflict, mode, library string) ([]Command, error) {
+	if len(conflicts) == 0 {
+		return merged, nil
+	}
+	if mode == "" || mode == conflictModeAsk {
+		mode = conflictModeFile
+		if isatty.IsTerminal(os.Stdin.Fd()) {
+			mode = conflictModeAsk
+		}
+	}
+	switch mode {
+	case conflictModeAsk:
+		merged, conflicts = resolveConflictsInteractively(merged, conflicts)
+	case conflictModeLocal, conflictModeRemote:
+		for _, conflict := range conflicts {
+			merged = applyConflictChoice(merged, conflict, mode)
+		}
+		return merged, nil
+	case conflictModeFile:
+	default:
+		return nil, fmt.Errorf("invalid conflict mode %q (use ask, file, local or remote)", mode)
+	}
+	if len(conflicts) == 0 {
+		return merged, nil
+	}
+	conflictsFilePath, err := getConflictsFilePath(library)
+	if err != nil {
+		return nil, err
+	}
+	if err = writeConflictsFile(conflictsFilePath, conflicts); err != nil {
+		return nil, err
+	}
+	fmt.Printf("%d conflict(s) written to %s, edit it and run 'katip resolve'\n", len(conflicts), conflictsFilePath)
+	return merged, nil
+}
+
+// Header of a conflict block in the conflicts file
+var conflictHeaderPattern = regexp.MustCompile(`^# conflict [0-9]+ .*\bids: (.*)$`)
+
+// Writes conflicts with git style markers. Every block keeps the commands
+// which are left between the markers once they are removed.
+func writeConflictsFile(path string, conflicts []mergeConflict) error {
+	var buf bytes.Buffer
+	buf.WriteString("# Keep the versions you want in each block and remove the markers.\n")
+	buf.WriteString("# An empty block deletes the command. Then run 'katip resolve'.\n")
+	for i, conflict := range conflicts {
+		fields := ""
+		if len(conflict.Fields) > 0 {
+			fields = " on " + strings.Join(conflict.Fields, ",")
+		}
+		fmt.Fprintf(&buf, "\n# conflict %d (%s%s) ids: %s\n", i+1, conflict.Kind, fields, strings.Join(conflictIDs(conflict), " "))
+		buf.WriteString("<<<<<<< local\n")
+		if err := writeConflictSide(&buf, conflict.Local); err != nil {
+			return err
+		}
+		buf.WriteString("=======\n")
+		if err := writeConflictSide(&buf, conflict.Remote); err != nil {
+			return err
+		}
+		buf.WriteString(">>>>>>> remote\n")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func writeConflictSide(w io.Writer, command *Command) error {
+	if command == nil {
+		return nil
+	}
+	content, err := marshalJSON(command)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// A resolved block of the conflicts file
+type conflictResolution struct {
+	IDs      []string
+	Commands []Command
+}
+
+// Reads resolutions from an edited conflicts file
+func readConflictsFile(path string) ([]conflictResolution, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var resolutions []conflictResolution
+	var content []string
+	flush := func() error {
+		if len(resolutions) == 0 {
+			return nil
+		}
+		decoder := json.NewDecoder(strings.NewReader(strings.Join(content, "\n")))
+		current := &resolutions[len(resolutions)-1]
+		for {
+			var command Command
+			err := decoder.Decode(&command)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return fmt.Errorf("conflict for %s: %v", strings.Join(current.IDs, " "), err)
+			}
+			current.Commands = append(current.Commands, command)
+		}
+		content = nil
+		return nil
+	}
+	for _, line := range strings.Split(string(file), "\n") {
+		if match := conflictHeaderPattern.FindStringSubmatch(line); match != nil {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			resolutions = append(resolutions, conflictResolution{IDs: strings.Fields(match[1])})
+			continue
+		}
+		if strings.HasPrefix(line, "<<<<<<<") || strings.HasPrefix(line, "=======") || strings.HasPrefix(line, ">>>>>>>") {
+			return nil, fmt.Errorf("unresolved conflict markers left in %s", path)
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		content = append(content, line)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return resolutions, nil
+}
+
+// Applies resolutions of the conflicts file of library to its commands and
+// removes the file. Returns the number of resolved conflicts.
+func resolveLibraryConflicts(library, conflictsFilePath string) (int, error) {
+	resolutions, err := readConflictsFile(conflictsFilePath)
+	if err != nil {
+		return 0, err
+	}
+	commands, err := readLibraryCommands(library)
+	if err != nil {
+		return 0, err
+	}
+	for _, resolution := range resolutions {
+		commands.Commands = replaceConflictingCommands(commands.Commands, resolution.IDs, resolution.Commands)
+	}
+	if err = writeLibraryCommands(library, *commands); err != nil {
+		return 0, err
+	}
+	return len(resolutions), os.Remove(conflictsFilePath)
+}
+
+// resolveCmd represents the resolve command
+var resolveCmd = &cobra.Command{
+	Use:   "resolve",
+	Short: "Applies resolved sync conflicts",
+	Long: `Applies the conflicts files written by sync after you kept the versions you
+want in each block and removed the conflict markers. Conflicts are applied to
+the library they come from.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		libraries, err := getLibraryNames()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		resolved := 0
+		for _, library := range libraries {
+			conflictsFilePath, err := getConflictsFilePath(library)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if _, err := os.Stat(conflictsFilePath); os.IsNotExist(err) {
+				continue
+			}
+			count, err := resolveLibraryConflicts(library, conflictsFilePath)
+			if err != nil {
+				fmt.Printf("resolve error in library %s: %v\n", library, err)
+				return
+			}
+			commitStoreChangeOrWarn(fmt.Sprintf("Resolve %d conflict(s) in library %s", count, library))
+			fmt.Printf("%d conflict(s) resolved in library %s\n", count, library)
+			resolved++
+		}
+		if resolved == 0 {
+			fmt.Println("There are no conflicts to resolve")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resolveCmd)
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestResolveAppliesConflictsToTheirLibrary(t *testing.T) {
+	t.Setenv(homeEnvVariable, t.TempDir())
+	for _, library := range []string{defaultLibrary, "work"} {
+		path, err := getLibraryCommandsFilePath(library)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = writeLibraryCommands(library, Commands{Commands: []Command{{ID: "a", Command: "echo " + library}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a sync of library work conflicts, then the user switches to default
+	local := Command{ID: "a", Command: "echo work", Description: "local"}
+	remote := Command{ID: "a", Command: "echo work", Description: "remote"}
+	conflicts := []mergeConflict{{Kind: conflictEditEdit, Fields: []string{"description"}, Local: &local, Remote: &remote}}
+	if _, err := resolveConflicts([]Command{local}, conflicts, conflictModeFile, "work"); err != nil {
+		t.Fatal(err)
+	}
+	conflictsFilePath, _ := getConflictsFilePath("work")
+	content, err := ioutil.ReadFile(conflictsFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// keep the remote version
+	content = regexp.MustCompile(`(?s)<<<<<<< local\n.*?=======\n(.*?)>>>>>>> remote\n`).ReplaceAll(content, []byte("$1"))
+	if err = ioutil.WriteFile(conflictsFilePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	resolveCmd.Run(resolveCmd, nil)
+
+	work := readStoreCommands(t, "work")
+	if work["a"].Description != "remote" {
+		t.Errorf("conflict of work is resolved to %q", work["a"].Description)
+	}
+	if commands := readStoreCommands(t, defaultLibrary); commands["a"].Command != "echo default" || len(commands) != 1 {
+		t.Errorf("conflict of work is applied to default library: %+v", commands)
+	}
+	if _, err := os.Stat(conflictsFilePath); !os.IsNotExist(err) {
+		t.Error("conflicts file is not removed")
+	}
+}
+
+// A remote which counts fetches and has no commands
+type countingRemote struct {
+	fetches int
+}
+
+func (remote *countingRemote) Name() string { return "counting" }
+
+func (remote *countingRemote) Fetch() (*Commands, string, error) {
+	remote.fetches++
+	return nil, "", nil
+}
+
+func (remote *countingRemote) Store(commands *Commands, version string) error { return nil }
+
+func TestSyncRefusesUnresolvedConflicts(t *testing.T) {
+	t.Setenv(homeEnvVariable, t.TempDir())
+	path, err := getLibraryCommandsFilePath(defaultLibrary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeLibraryCommands(defaultLibrary, Commands{Commands: []Command{{ID: "a", Command: "echo a"}}}); err != nil {
+		t.Fatal(err)
+	}
+	local := Command{ID: "a", Command: "echo a", Description: "local"}
+	remote := Command{ID: "a", Command: "echo a", Description: "remote"}
+	conflicts := []mergeConflict{{Kind: conflictEditEdit, Fields: []string{"description"}, Local: &local, Remote: &remote}}
+	if _, err = resolveConflicts([]Command{local}, conflicts, conflictModeFile, defaultLibrary); err != nil {
+		t.Fatal(err)
+	}
+	conflictsFilePath, _ := getConflictsFilePath(defaultLibrary)
+	unresolved, err := ioutil.ReadFile(conflictsFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counting := &countingRemote{}
+	if err = syncCommands(counting, false); err == nil {
+		t.Fatal("sync ran with unresolved conflicts")
+	}
+	if content, _ := ioutil.ReadFile(conflictsFilePath); string(content) != string(unresolved) || counting.fetches != 0 {
+		t.Fatal("sync touched unresolved conflicts")
+	}
+
+	if err = os.Remove(conflictsFilePath); err != nil {
+		t.Fatal(err)
+	}
+	if err = syncCommands(counting, false); err != nil || counting.fetches != 1 {
+		t.Fatalf("sync after resolving: %v, %d fetches", err, counting.fetches)
+	}
+}
@@ -75,14 +75,16 @@ var rmCmd = &cobra.Command{
 		rmIndex--
 		if rmIndex >= 0 && rmIndex <= len(commands.Commands) {
 			// ask for delete confirmation
-			fmt.Println(commands.Commands[rmIndex].Command + " :: " + commands.Commands[rmIndex].Description + " :: " + commands.Commands[rmIndex].Alias)
+			fmt.Println(formatCommandLine(commands.Commands[rmIndex]))
 			if askForConfirmation(confirmationTextForDeleteCommand) {
+				removedCommand := commands.Commands[rmIndex]
 				commands.Commands = append(commands.Commands[:rmIndex], commands.Commands[rmIndex+1:]...)
 				err = writeCommandsToFile(*commands)
 				if err != nil {
 					fmt.Println(err)
 					return
 				}
+				commitStoreChangeOrWarn("Remove " + describeCommand(removedCommand))
 				fmt.Println("Command is successfully removed")
 				return
 			}
@@ -24,10 +24,10 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 
-	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
 )
 
@@ -56,35 +56,65 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.katip.yaml)")
-
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $XDG_CONFIG_HOME/katip/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&colorMode, "color", "auto", "colorize output: auto, always or never")
+	rootCmd.PersistentFlags().StringVar(&libraryFlag, "library", "", "library to use (default is $KATIP_LIBRARY or the one selected with \"katip library use\")")
+	rootCmd.PersistentFlags().BoolVar(&allLibraries, "all-libraries", false, "search commands of every library")
+	rootCmd.PersistentFlags().BoolVar(&allScopes, "all-scopes", false, "include commands scoped to other directories or repositories")
+	viper.BindPFlag("color", rootCmd.PersistentFlags().Lookup("color"))
 }
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
+	if err := migrateLegacyDirs(); err != nil {
+		fmt.Println("error while moving files to new location:", err)
+		os.Exit(1)
+	}
 	if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
 	} else {
-		// Find home directory.
-		home, err := homedir.Dir()
+		configFilePath, err := getConfigFilePath()
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
-
-		// Search config in home directory with name ".katip" (without extension).
-		viper.AddConfigPath(home)
-		viper.SetConfigName(".katip")
+		viper.SetConfigFile(configFilePath)
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
+	setConfigDefaults()
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+	} else if _, notFound := err.(viper.ConfigFileNotFoundError); !notFound && !os.IsNotExist(err) {
+		// keep going with defaults, so that "katip config edit" can fix it
+		fmt.Fprintln(os.Stderr, "error while reading config file:", err)
+	}
+
+	colorMode = viper.GetString("color")
+	if err := configureColor(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// Sets key in config and writes config file, creating it if there is none.
+// Only settings in the config file are written, not defaults or environment
+// overrides.
+func saveConfigValue(key string, value interface{}) error {
+	viper.Set(key, value)
+	configFile, err := getConfigFileUsed()
+	if err != nil {
+		return err
+	}
+	fileConfig, err := readConfigFile(configFile)
+	if err != nil {
+		return err
+	}
+	fileConfig.Set(key, value)
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+		return err
 	}
+	return fileConfig.WriteConfigAs(configFile)
 }
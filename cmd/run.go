@@ -22,23 +22,32 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"bufio"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
-	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
+var runFailFast, runDryRun, runPrint, runCapture bool
+var runTimeout, runRetryDelay time.Duration
+var runRetries int
+
 // runCmd represents the run command
 var runCmd = &cobra.Command{
 	Use:   "run [ARGS]",
 	Short: "Executes a saved command",
 	Long:  `Give this command a hint about your saved command (alias, description or command itself (it is not logical to run the command you know through katip instead of writing it directly btw)) and your command will be executed.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		// keep stdout to the rendered command when printing it
+		var ui io.Writer = os.Stdout
+		if runPrint {
+			ui = os.Stderr
+		}
 		// check if app directory exists
 		isAppDirExists, err := checkIfAppDirExists()
 		if err != nil || isAppDirExists == false {
@@ -50,94 +59,162 @@ var runCmd = &cobra.Command{
 		if !checkIfCommandsFileExists() {
 			err = createCommandsFile()
 			if err != nil {
-				fmt.Println("error occured while creating commands file: ", err)
+				fmt.Fprintln(ui, "error occured while creating commands file: ", err)
 				return
 			}
-			fmt.Println(warningCommandsFileNotExist)
-			return
 		}
 
 		// concatenate args into the single string
 		concatenatedArgs := strings.Join(args[:], " ")
-		commands, err := getCommands()
+		commands, err := getAllCommands()
 		if err != nil {
-			fmt.Println("error while getting commands:", err)
+			fmt.Fprintln(ui, "error while getting commands:", err)
 			return
 		}
+		commands.Commands = applyScopes(commands.Commands, allScopes)
 
 		if len(commands.Commands) == 0 {
-			fmt.Println(warningCommandsFileNotExist)
+			fmt.Fprintln(ui, warningCommandsFileNotExist)
 			return
 		}
 
 		var concatenatedCommands, matches []string
 		var cmdIndexes []int
 		for i, command := range commands.Commands {
-			concatenatedCommands = append(concatenatedCommands, strconv.Itoa(i)+" - "+command.Command+" :: "+command.Description+" :: "+command.Alias)
+			concatenatedCommands = append(concatenatedCommands, strconv.Itoa(i)+" - "+getCommandText(command)+" :: "+command.Description+" :: "+command.Alias)
+		}
+		matchCommand, err := newCommandMatcher(concatenatedArgs)
+		if err != nil {
+			fmt.Fprintln(ui, "error while searching argument:", err)
+			return
 		}
 		for i, concatenatedCommand := range concatenatedCommands {
-			isMatched, err := regexp.MatchString(concatenatedArgs, concatenatedCommand)
-			if err != nil {
-				fmt.Println("error while searching argument:", err)
-				return
-			} else if isMatched {
+			if matchCommand(concatenatedCommand) {
 				cmdIndexes = append(cmdIndexes, i)
 				matches = append(matches, concatenatedCommand)
 			}
 		}
 		if len(matches) == 0 {
-			fmt.Println("No saved commands matches the pattern: ", concatenatedArgs)
+			fmt.Fprintln(ui, "No saved commands matches the pattern: ", concatenatedArgs)
 			return
 		}
 		// check if a single or multiple commands are found
 		if len(matches) > 1 {
 			// if there are more than one possible commands to execute
-			fmt.Printf("Multiple commands found. Please enter the index of command you want to execute: \n\n")
-			for _, match := range matches {
-				fmt.Println(match)
+			fmt.Fprintf(ui, "Multiple commands found. Please enter the index of command you want to execute: \n\n")
+			for _, cmdIndex := range cmdIndexes {
+				fmt.Fprintln(ui, strconv.Itoa(cmdIndex)+" - "+formatCommandLine(commands.Commands[cmdIndex]))
 			}
-			var cmdIndexStr string
-			scanner := bufio.NewScanner(os.Stdin)
-			fmt.Printf("\nIndex of command you want to execute: ")
-			scanner.Scan()
-			cmdIndexStr = scanner.Text()
+			fmt.Fprintf(ui, "\nIndex of command you want to execute: ")
+			cmdIndexStr, _ := readLine()
 			cmdIndex, err := strconv.Atoi(cmdIndexStr)
 			if err != nil {
-				fmt.Println("Invalid input")
+				fmt.Fprintln(ui, "Invalid input")
 				return
 			}
 			if isIntInSlice(cmdIndex, cmdIndexes) {
 				// ask for confirmation to execute
-				fmt.Println("\n" + commands.Commands[cmdIndex].Command + " :: " + commands.Commands[cmdIndex].Description + " :: " + commands.Commands[cmdIndex].Alias)
-				if askForConfirmation(confirmationTextForRunCommand) {
-					out, err := exec.Command("bash", "-c", commands.Commands[cmdIndex].Command).Output()
-					if err != nil {
-						fmt.Println("error : ", err)
-					}
-					fmt.Printf("\n%s\n", out)
+				fmt.Fprintln(ui, "\n"+formatCommandLine(commands.Commands[cmdIndex]))
+				if confirmRun() {
+					runSavedCommand(commands.Commands[cmdIndex], nil)
 					return
 				}
-				fmt.Println("Aborted")
+				fmt.Fprintln(ui, "Aborted")
 				return
 			}
-			fmt.Println("There is no command with this index")
+			fmt.Fprintln(ui, "There is no command with this index")
 			return
 		}
 		// if there is one possible command to execute
-		fmt.Printf("%s\n", matches[0])
-		if askForConfirmation(confirmationTextForRunCommand) {
-			out, err := exec.Command("bash", "-c", commands.Commands[cmdIndexes[0]].Command).Output()
-			if err != nil {
-				fmt.Println("error : ", err)
-			}
-			fmt.Printf("\n%s\n", out)
+		fmt.Fprintf(ui, "%s\n", strconv.Itoa(cmdIndexes[0])+" - "+formatCommandLine(commands.Commands[cmdIndexes[0]]))
+		if confirmRun() {
+			runSavedCommand(commands.Commands[cmdIndexes[0]], nil)
 			return
 		}
-		fmt.Println("Aborted")
+		fmt.Fprintln(ui, "Aborted")
 		return
 	},
 }
 
+// Runs command, prints its output and records its usage. Placeholders
+// without a value are asked for. Every run is recorded in run history, with
+// its output if it is captured.
+func runSavedCommand(command Command, values map[string]string) {
+	if runDryRun || runPrint {
+		if err := previewSavedCommand(command); err != nil {
+			fmt.Fprintln(os.Stderr, "error : ", err)
+		}
+		return
+	}
+	run := startRun(command)
+	var stdout, stderr io.Writer = os.Stdout, os.Stderr
+	var capture *captureWriter
+	var err error
+	if (runCapture || command.Capture) && command.Runbook == "" {
+		if capture, err = newCaptureWriter(); err != nil {
+			fmt.Println("error : ", err)
+			return
+		}
+		stdout, stderr = io.MultiWriter(os.Stdout, capture), io.MultiWriter(os.Stderr, capture)
+		run.Captured = true
+	}
+	switch {
+	case command.Runbook != "":
+		name := command.Alias
+		if name == "" {
+			name = command.ID
+		}
+		err = runRunbook(name, command.Runbook, command.Placeholders)
+	case isWorkflow(command):
+		err = runWorkflow(command, runFailFast, stdout, stderr)
+	default:
+		if run.Values, err = resolvePlaceholders(command.Command, command.Placeholders, values, command.Variables, true); err != nil {
+			fmt.Println("error : ", err)
+			return
+		}
+		command.Command = replacePlaceholders(command.Command, run.Values)
+		run.Command = command.Command
+		var options runOptions
+		if options, err = getRunOptions(command); err != nil {
+			fmt.Println("error : ", err)
+			return
+		}
+		fmt.Println()
+		run.StartedAt = time.Now()
+		err = runWithRetries(command, options, stdout, stderr)
+	}
+	if err != nil {
+		fmt.Println("error : ", err)
+	}
+	if err := recordRun(&run, err); err != nil {
+		fmt.Println("error while saving run history:", err)
+	} else if capture != nil {
+		if err := saveCapturedOutput(run, capture); err != nil {
+			fmt.Println("error while saving output:", err)
+		}
+	}
+	if err := markCommandAsUsed(command); err != nil {
+		fmt.Println("error while saving usage statistics:", err)
+	}
+}
+
+// Asks before running a command unless confirm is set to never. Nothing is
+// asked for when the command is only shown.
+func confirmRun() bool {
+	if viper.GetString("confirm") == "never" || runDryRun || runPrint {
+		return true
+	}
+	return askForConfirmation(confirmationTextForRunCommand)
+}
+
 func init() {
 	rootCmd.AddCommand(runCmd)
+	runCmd.Flags().BoolVar(&runDryRun, "dry-run", false, "show what would be run, without running it")
+	runCmd.Flags().BoolVar(&runPrint, "print", false, "print only the rendered command, without running it")
+	runFlags = runCmd.Flags()
+	runCmd.Flags().DurationVar(&runTimeout, "timeout", 0, "kill the command if it runs longer than this, such as 30s or 5m")
+	runCmd.Flags().IntVar(&runRetries, "retries", 0, "number of times to retry a failing command")
+	runCmd.Flags().DurationVar(&runRetryDelay, "retry-delay", time.Second, "time to wait between retries")
+	runCmd.Flags().BoolVar(&runCapture, "capture", false, "save the output of the command, to be shown with \"katip runs show\"")
+	runCmd.Flags().BoolVar(&runFailFast, "fail-fast", false, "stop a script at its first failing line (shells supporting set -e)")
 }
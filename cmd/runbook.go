@@ -0,0 +1,364 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+const runbooksDirName = "runbooks"
+
+var (
+	runbookSave         bool
+	runbookTranscript   string
+	runbookPlaceholders []string
+)
+
+// Interpreters of the fenced block languages which are run as steps. Blocks
+// of other languages are part of the prose.
+var runbookInterpreters = map[string]string{
+	"sh":      "sh",
+	"bash":    "bash",
+	"zsh":     "zsh",
+	"fish":    "fish",
+	"shell":   "",
+	"console": "",
+}
+
+var proseColor = color.New(color.Faint)
+
+// A fenced shell block of a runbook with the prose before it
+type runbookStep struct {
+	Prose       string
+	Code        string
+	Interpreter string
+}
+
+// Splits a Markdown runbook into steps. Returns the first heading as title.
+// Prose after the last block is returned as the last step without code.
+func parseRunbook(text string) (string, []runbookStep) {
+	var title string
+	var steps []runbookStep
+	var prose, code []string
+	var fence, language string
+	inBlock := false
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !inBlock && title == "" && strings.HasPrefix(trimmed, "# ") {
+			title = strings.TrimSpace(trimmed[2:])
+		}
+		switch {
+		case !inBlock && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")):
+			fence = trimmed[:3]
+			language = strings.ToLower(strings.TrimSpace(strings.Trim(trimmed, "`~")))
+			if fields := strings.Fields(language); len(fields) > 0 {
+				language = fields[0]
+			}
+			inBlock = true
+			code = nil
+			if _, ok := runbookInterpreters[language]; !ok {
+				prose = append(prose, line)
+			}
+		case inBlock && strings.HasPrefix(trimmed, fence):
+			inBlock = false
+			interpreter, ok := runbookInterpreters[language]
+			if !ok {
+				prose = append(prose, line)
+				continue
+			}
+			if language == "console" {
+				code = getConsoleCommands(code)
+			}
+			steps = append(steps, runbookStep{
+				Prose:       strings.TrimSpace(strings.Join(prose, "\n")),
+				Code:        strings.Join(code, "\n"),
+				Interpreter: interpreter,
+			})
+			prose = nil
+		case inBlock:
+			if _, ok := runbookInterpreters[language]; ok {
+				code = append(code, line)
+			} else {
+				prose = append(prose, line)
+			}
+		default:
+			prose = append(prose, line)
+		}
+	}
+	if rest := strings.TrimSpace(strings.Join(prose, "\n")); rest != "" {
+		steps = append(steps, runbookStep{Prose: rest})
+	}
+	return title, steps
+}
+
+// Returns commands of a console block, the lines starting with a $ prompt
+func getConsoleCommands(lines []string) []string {
+	var commands []string
+	for _, line := range lines {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "$ ") {
+			commands = append(commands, trimmed[2:])
+		}
+	}
+	return commands
+}
+
+// Returns path of the transcript of a runbook run started at start
+func getTranscriptPath(name string, start time.Time) (string, error) {
+	if runbookTranscript != "" {
+		return runbookTranscript, nil
+	}
+	stateDirPath, err := getStateDirPath()
+	if err != nil {
+		return "", err
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == os.PathSeparator || r == ' ' {
+			return '-'
+		}
+		return r
+	}, strings.TrimSuffix(name, filepath.Ext(name)))
+	return filepath.Join(stateDirPath, runbooksDirName, name+"-"+start.Format("20060102-150405")+".md"), nil
+}
+
+// Walks through the steps of a runbook, letting user run, skip or edit each
+// of them. Placeholders of the steps declared in placeholders are asked for.
+// Everything which is run is recorded in a Markdown transcript.
+func runRunbook(name, text string, placeholders []string) error {
+	title, steps := parseRunbook(text)
+	if title == "" {
+		title = name
+	}
+	start := time.Now()
+	hostname, _ := os.Hostname()
+	var transcript bytes.Buffer
+	fmt.Fprintf(&transcript, "# %s\n\nRunbook %s run on %s at %s\n", title, name, hostname, start.Format(time.RFC3339))
+
+	total := 0
+	for _, step := range steps {
+		if step.Code != "" {
+			total++
+		}
+	}
+	number := 0
+	for _, step := range steps {
+		if step.Prose != "" {
+			fmt.Println(proseColor.Sprint(step.Prose))
+			fmt.Println()
+		}
+		if step.Code == "" {
+			continue
+		}
+		number++
+		quit, err := runRunbookStep(step, placeholders, number, total, &transcript)
+		if err != nil {
+			return err
+		}
+		if quit {
+			fmt.Fprintf(&transcript, "\nQuit before step %d of %d.\n", number, total)
+			break
+		}
+	}
+
+	transcriptPath, err := getTranscriptPath(name, start)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(transcriptPath), 0755); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(transcriptPath, transcript.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Println("Transcript is saved to", transcriptPath)
+	return nil
+}
+
+// Asks what to do with a step until it is run or skipped. Returns true if
+// user quits the runbook.
+func runRunbookStep(step runbookStep, placeholders []string, number, total int, transcript io.Writer) (bool, error) {
+	code := step.Code
+	edited := false
+	for {
+		fmt.Printf("Step %d of %d:\n", number, total)
+		for _, line := range strings.Split(code, "\n") {
+			fmt.Println("    " + highlightCommand(line))
+		}
+		fmt.Print("\n[r]un, [s]kip, [e]dit or [q]uit? ")
+		answer, err := readLine()
+		if err != nil {
+			return true, nil
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "r", "run":
+			fmt.Fprintf(transcript, "\n## Step %d of %d\n\n", number, total)
+			if edited {
+				fmt.Fprintln(transcript, "Edited before running.")
+				fmt.Fprintln(transcript)
+			}
+			runRunbookCode(code, step.Interpreter, placeholders, transcript)
+			return false, nil
+		case "s", "skip":
+			fmt.Fprintf(transcript, "\n## Step %d of %d\n\nSkipped:\n\n```\n%s\n```\n", number, total, code)
+			fmt.Println()
+			return false, nil
+		case "e", "edit":
+			newCode, err := editText(code)
+			if err != nil {
+				fmt.Println("error occured while opening editor:", err)
+				continue
+			}
+			code, edited = newCode, true
+		case "q", "quit":
+			return true, nil
+		default:
+			fmt.Println("invalid input.")
+		}
+	}
+}
+
+// Runs code of a step, printing its output and recording it in transcript
+func runRunbookCode(code, interpreter string, placeholders []string, transcript io.Writer) {
+	rendered, err := renderPlaceholders(code, placeholders, nil, nil, true)
+	if err != nil {
+		fmt.Fprintf(transcript, "Not run: %v\n", err)
+		fmt.Println("error : ", err)
+		return
+	}
+	fmt.Fprintf(transcript, "```\n%s\n```\n", rendered)
+	var output bytes.Buffer
+	start := time.Now()
+	err = runOnce(Command{Command: rendered, Interpreter: interpreter}, runOptions{},
+		io.MultiWriter(os.Stdout, &output), io.MultiWriter(os.Stderr, &output))
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if output.Len() > 0 {
+		fmt.Fprintf(transcript, "\nOutput:\n\n```\n%s\n```\n", strings.TrimRight(output.String(), "\n"))
+	}
+	if err != nil {
+		fmt.Fprintf(transcript, "\nFailed after %s: %v\n", elapsed, err)
+		fmt.Println(stepFailedColor.Sprintf("✗ %v (%s)", err, elapsed))
+	} else {
+		fmt.Fprintf(transcript, "\nSucceeded in %s\n", elapsed)
+		fmt.Println(stepSucceededColor.Sprintf("✓ (%s)", elapsed))
+	}
+	fmt.Println()
+}
+
+// Opens text in the configured editor and returns it as it is saved
+func editText(text string) (string, error) {
+	file, err := ioutil.TempFile("", "katip-edit-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(text + "\n")
+	file.Close()
+	if err != nil {
+		return "", err
+	}
+	if err = openInEditor(file.Name()); err != nil {
+		return "", err
+	}
+	content, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// Saves runbook into the library as a single command
+func saveRunbook(cmd *cobra.Command, path, text string) error {
+	title, steps := parseRunbook(text)
+	if len(steps) == 0 {
+		return fmt.Errorf("%s has no steps", path)
+	}
+	description, _ := cmd.Flags().GetString("description")
+	if description == "" {
+		description = title
+	}
+	alias, _ := cmd.Flags().GetString("alias")
+	commands, err := getOrCreateCommands()
+	if err != nil {
+		return err
+	}
+	command := Command{
+		ID:           newCommandID(),
+		Description:  description,
+		Alias:        alias,
+		Runbook:      text,
+		Placeholders: runbookPlaceholders,
+		CreatedAt:    time.Now(),
+	}
+	commands.Commands = append(commands.Commands, command)
+	if err = writeCommandsToFile(*commands); err != nil {
+		return err
+	}
+	commitStoreChangeOrWarn("Add " + describeCommand(command))
+	return nil
+}
+
+// runbookCmd represents the runbook command
+var runbookCmd = &cobra.Command{
+	Use:   "runbook FILE",
+	Short: "Walks through a Markdown runbook step by step",
+	Long: `Runs the fenced shell blocks of a Markdown document one by one, showing the
+prose around them. Each step can be run, skipped or edited before it is run,
+and everything is recorded in a transcript. With --save, the runbook is saved
+as a command instead, which katip run walks through.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		content, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			fmt.Println("error while reading runbook:", err)
+			return
+		}
+		if runbookSave {
+			if err = saveRunbook(cmd, args[0], string(content)); err != nil {
+				fmt.Println("error while saving runbook:", err)
+				return
+			}
+			fmt.Println("Runbook is successfully saved")
+			return
+		}
+		if err = runRunbook(filepath.Base(args[0]), string(content), runbookPlaceholders); err != nil {
+			fmt.Println("error : ", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runbookCmd)
+	runbookCmd.Flags().BoolVar(&runbookSave, "save", false, "save runbook as a command instead of running it")
+	runbookCmd.Flags().StringP("description", "d", "", "description of the saved runbook (default is its title)")
+	runbookCmd.Flags().StringP("alias", "a", "", "alias of the saved runbook")
+	runbookCmd.Flags().StringVar(&runbookTranscript, "transcript", "", "path of the transcript (default is in the state directory)")
+	runbookCmd.Flags().StringSliceVar(&runbookPlaceholders, "placeholder", nil, "name of a <placeholder> in the steps asked for when they are run")
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRunbook(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		title string
+		steps []runbookStep
+	}{
+		{
+			name:  "shell blocks",
+			text:  "# Deploy\n\nBuild it first.\n\n```bash\nmake\n```\n\nThen ship.\n\n```sh\n./ship\n./notify\n```\n",
+			title: "Deploy",
+			steps: []runbookStep{
+				{Prose: "# Deploy\n\nBuild it first.", Code: "make", Interpreter: "bash"},
+				{Prose: "Then ship.", Code: "./ship\n./notify", Interpreter: "sh"},
+			},
+		},
+		{
+			name:  "other languages are prose",
+			text:  "# Config\n\n```yaml\nkey: value\n```\n\n~~~shell\ncat config.yaml\n~~~\n",
+			title: "Config",
+			steps: []runbookStep{
+				{Prose: "# Config\n\n```yaml\nkey: value\n```", Code: "cat config.yaml"},
+			},
+		},
+		{
+			name:  "console prompts",
+			text:  "```console\n$ uname -a\nLinux host\n$ id\n```\n",
+			steps: []runbookStep{{Code: "uname -a\nid"}},
+		},
+		{
+			name:  "prose after the last block",
+			text:  "```bash title=\"x\"\nls\n```\n\n## Done\n\nAll good.\n",
+			steps: []runbookStep{{Code: "ls", Interpreter: "bash"}, {Prose: "## Done\n\nAll good."}},
+		},
+		{
+			name:  "first heading is the title",
+			text:  "Intro\n\n# First\n\n# Second\n",
+			title: "First",
+			steps: []runbookStep{{Prose: "Intro\n\n# First\n\n# Second"}},
+		},
+	}
+	for _, test := range tests {
+		title, steps := parseRunbook(test.text)
+		if title != test.title || !reflect.DeepEqual(steps, test.steps) {
+			t.Errorf("%s: parseRunbook() = %q %+v, want %q %+v", test.name, title, steps, test.title, test.steps)
+		}
+	}
+}
+
+func TestRunRunbook(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(homeEnvVariable, home)
+	defer func(transcript string) { runbookTranscript = transcript }(runbookTranscript)
+	runbookTranscript = filepath.Join(home, "transcript.md")
+
+	text := "# Greet\n\n```sh\necho hello <who>\n```\n\n```sh\necho skipped\n```\n\n```sh\necho never\n```\n"
+	tests := []struct {
+		input string
+		want  []string
+		not   []string
+	}{
+		{
+			input: "r\nworld\ns\nq\n",
+			want:  []string{"# Greet", "## Step 1 of 3", "echo hello world", "hello world", "Succeeded", "Skipped:", "echo skipped", "Quit before step 3 of 3."},
+			not:   []string{"echo never"},
+		},
+		// runbooks stop when input ends
+		{
+			input: "s\n",
+			want:  []string{"Skipped:", "Quit before step 2 of 3."},
+			not:   []string{"hello world", "Succeeded"},
+		},
+	}
+	for _, test := range tests {
+		setStdin(t, test.input)
+		if err := runRunbook("greet.md", text, []string{"who"}); err != nil {
+			t.Fatal(err)
+		}
+		transcript, err := ioutil.ReadFile(runbookTranscript)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range test.want {
+			if !strings.Contains(string(transcript), want) {
+				t.Errorf("input %q: transcript %q has no %q", test.input, transcript, want)
+			}
+		}
+		for _, not := range test.not {
+			if strings.Contains(string(transcript), not) {
+				t.Errorf("input %q: transcript %q has %q", test.input, transcript, not)
+			}
+		}
+	}
+}
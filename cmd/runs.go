@@ -0,0 +1,200 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+)
+
+var (
+	runsFailed bool
+	runsSince  string
+)
+
+// runsCmd represents the runs command
+var runsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "Lists past runs of saved commands",
+	Long: `Every "katip run" is recorded with the command it ran, where and when it ran,
+how long it took and how it exited. Repeat a run with "katip rerun RUN-ID".
+Output of runs made with --capture is shown with "katip runs show RUN-ID".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runs, err := getRuns()
+		if err != nil {
+			fmt.Println("error while getting runs:", err)
+			return
+		}
+		var since time.Time
+		if runsSince != "" {
+			if since, err = parseSince(runsSince, time.Now()); err != nil {
+				fmt.Println(err)
+				return
+			}
+		}
+		var shown []Run
+		for _, run := range runs {
+			if runsFailed && run.ExitCode == 0 || run.StartedAt.Before(since) {
+				continue
+			}
+			shown = append(shown, run)
+		}
+		if len(shown) == 0 {
+			fmt.Println("No runs found")
+			return
+		}
+		printRunsAsTable(shown)
+	},
+}
+
+// Prints runs as table. Hosts are shown when runs come from more than one.
+func printRunsAsTable(runs []Run) {
+	showHost := false
+	for _, run := range runs {
+		showHost = showHost || run.Host != runs[0].Host
+	}
+	header := table.Row{"ID", "Started", "Duration", "Exit", "Command", "Directory"}
+	if showHost {
+		header = append(header, "Host")
+	}
+	var rows []table.Row
+	for _, run := range runs {
+		row := table.Row{run.ID, formatCommandTime(run.StartedAt), formatRunDuration(run.Duration), run.ExitCode, formatScriptCell(run.Command, 0), run.Dir}
+		if showHost {
+			row = append(row, run.Host)
+		}
+		rows = append(rows, row)
+	}
+	t := table.NewWriter()
+	t.Style().Options.SeparateRows = true
+	t.AppendHeader(header)
+	t.AppendRows(rows)
+	printWithPager(t.Render())
+}
+
+var runsShowCmd = &cobra.Command{
+	Use:   "show RUN-ID",
+	Short: "Shows a past run and its captured output",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		run, err := findRun(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Run %d: %s\n", run.ID, run.Command)
+		fmt.Fprintf(os.Stderr, "Started %s in %s on %s, exited with %d after %s\n\n", formatCommandTime(run.StartedAt), run.Dir, run.Host, run.ExitCode, formatRunDuration(run.Duration))
+		if !run.Captured {
+			fmt.Fprintln(os.Stderr, "Output of this run was not captured, run commands with --capture to keep their output")
+			return
+		}
+		logPath, err := getCaptureLogPath(run.ID)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		output, err := ioutil.ReadFile(logPath)
+		if os.IsNotExist(err) {
+			fmt.Fprintln(os.Stderr, "Output of this run is removed, see capture.max_logs and capture.max_age settings")
+			return
+		} else if err != nil {
+			fmt.Println("error while reading output:", err)
+			return
+		}
+		os.Stdout.Write(output)
+	},
+}
+
+// Returns the saved command run ran. Commands of other libraries are looked
+// up in the library they were run from.
+func getRunCommand(run Run) (Command, error) {
+	commands, err := getAllCommands()
+	if err != nil {
+		return Command{}, err
+	}
+	for _, command := range commands.Commands {
+		if command.ID == run.CommandID {
+			return command, nil
+		}
+	}
+	if run.Library != "" {
+		libraryCommands, err := readLibraryCommands(run.Library)
+		if err != nil {
+			return Command{}, err
+		}
+		for _, command := range libraryCommands.Commands {
+			if command.ID == run.CommandID {
+				command.Layer, command.Library = userLayer, run.Library
+				return command, nil
+			}
+		}
+	}
+	return Command{}, fmt.Errorf("command %s of run %d no longer exists", run.CommandID, run.ID)
+}
+
+// rerunCmd represents the rerun command
+var rerunCmd = &cobra.Command{
+	Use:   "rerun RUN-ID",
+	Short: "Runs a past run again",
+	Long:  `Runs the saved command of a past run again, in the directory it ran in and with the same placeholder values.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		run, err := findRun(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		command, err := getRunCommand(run)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err = os.Chdir(run.Dir); err != nil {
+			fmt.Println("error while changing directory:", err)
+			return
+		}
+		shown := command
+		if !isWorkflow(command) && command.Runbook == "" {
+			shown.Command = replacePlaceholders(command.Command, run.Values)
+		}
+		fmt.Println(strconv.Itoa(run.ID) + " - " + formatCommandLine(shown))
+		fmt.Println("in", run.Dir)
+		if confirmRun() {
+			runSavedCommand(command, run.Values)
+			return
+		}
+		fmt.Println("Aborted")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runsCmd, rerunCmd)
+	runsCmd.AddCommand(runsShowCmd)
+	runsCmd.Flags().BoolVar(&runsFailed, "failed", false, "show only runs which failed")
+	runsCmd.Flags().StringVar(&runsSince, "since", "", "show only runs started within a duration such as 12h, 1d or 2w, or since a date such as 2006-01-02")
+}
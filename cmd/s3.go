@@ -0,0 +1,180 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var defaultS3Region = "us-east-1"
+
+// Credentials and location of the object holding commands
+type s3Config struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	region       string
+}
+
+// Creates S3 remote from sync.s3 settings. Any S3 compatible storage can be
+// used by setting sync.s3.endpoint, in which case path style URLs are used.
+func newS3Remote() (syncRemote, error) {
+	bucket := viper.GetString("sync.s3.bucket")
+	if bucket == "" {
+		return nil, fmt.Errorf("no S3 bucket configured, set sync.s3.bucket")
+	}
+	config := s3Config{
+		accessKey:    os.Getenv(viper.GetString("sync.s3.access_key_env")),
+		secretKey:    os.Getenv(viper.GetString("sync.s3.secret_key_env")),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		region:       viper.GetString("sync.s3.region"),
+	}
+	if config.accessKey == "" || config.secretKey == "" {
+		return nil, fmt.Errorf("S3 credentials are required, set %s and %s",
+			viper.GetString("sync.s3.access_key_env"), viper.GetString("sync.s3.secret_key_env"))
+	}
+
+	key := strings.TrimLeft(viper.GetString("sync.s3.key"), "/")
+	if key == "" {
+		key = "katip/" + getSyncFileName()
+	}
+	var objectURL string
+	if endpoint := strings.TrimRight(viper.GetString("sync.s3.endpoint"), "/"); endpoint != "" {
+		objectURL = endpoint + "/" + bucket + "/" + key
+	} else {
+		objectURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, config.region, key)
+	}
+	remote := newHTTPFileRemote("s3", objectURL)
+	remote.authorize = func(req *http.Request, payload []byte) error {
+		signS3Request(req, payload, config, time.Now().UTC())
+		return nil
+	}
+	return remote, nil
+}
+
+// Signs req with AWS Signature Version 4
+func signS3Request(req *http.Request, payload []byte, config s3Config, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if config.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", config.sessionToken)
+	}
+
+	// sign host and all x-amz-* headers
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + config.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+config.secretKey), date)
+	signingKey = hmacSHA256(signingKey, config.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		config.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Escapes path as required by S3 canonical requests
+func s3EscapePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	var sb strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// Returns query parameters sorted and escaped for S3 canonical requests
+func s3CanonicalQuery(query url.Values) string {
+	var keys []string
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, strings.Replace(url.QueryEscape(key), "+", "%20", -1)+"="+strings.Replace(url.QueryEscape(value), "+", "%20", -1))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,161 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var allScopes bool
+
+// Scope limits a command to directories or git repositories
+type Scope struct {
+	Paths   []string `json:"paths,omitempty" yaml:"paths,omitempty"`
+	Remotes []string `json:"remotes,omitempty" yaml:"remotes,omitempty"`
+}
+
+// Current directory and git remotes commands are matched against
+type scopeContext struct {
+	dir     string
+	remotes []string
+}
+
+// Detects working directory and remotes of the git repository in it
+func getScopeContext() scopeContext {
+	var context scopeContext
+	context.dir, _ = os.Getwd()
+	out, err := exec.Command("git", "remote", "-v").Output()
+	if err != nil {
+		return context
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		remote := normalizeRemoteURL(fields[1])
+		if !isStringInSlice(remote, context.remotes) {
+			context.remotes = append(context.remotes, remote)
+		}
+	}
+	return context
+}
+
+// scp like git URLs such as git@github.com:org/repo.git
+var scpLikeURLPattern = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.*)$`)
+
+// Normalizes a git remote URL to host/path form, so that SSH and HTTPS
+// remotes of the same repository are equal
+func normalizeRemoteURL(url string) string {
+	url = strings.TrimSpace(url)
+	if index := strings.Index(url, "://"); index >= 0 {
+		url = url[index+3:]
+		if at := strings.Index(url, "@"); at >= 0 && at < strings.Index(url+"/", "/") {
+			url = url[at+1:]
+		}
+	} else if match := scpLikeURLPattern.FindStringSubmatch(url); match != nil {
+		url = match[1] + "/" + match[2]
+	}
+	url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	return strings.ToLower(url)
+}
+
+// Expands leading ~ of path to home directory
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if homeDir, err := getHomeDirPath(); err == nil {
+			return filepath.Join(homeDir, p[1:])
+		}
+	}
+	return p
+}
+
+// Checks if dir or one of its parents matches the path glob
+func matchesScopePath(pattern, dir string) bool {
+	pattern = filepath.Clean(expandHome(pattern))
+	for {
+		if matched, _ := filepath.Match(pattern, dir); matched {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// Checks if command is scoped to directories or repositories
+func isScoped(command Command) bool {
+	return command.Scope != nil && (len(command.Scope.Paths) > 0 || len(command.Scope.Remotes) > 0)
+}
+
+// Checks if a scoped command applies in context
+func (context scopeContext) matches(command Command) bool {
+	if context.dir != "" {
+		for _, pattern := range command.Scope.Paths {
+			if matchesScopePath(pattern, context.dir) {
+				return true
+			}
+		}
+	}
+	for _, pattern := range command.Scope.Remotes {
+		pattern = normalizeRemoteURL(pattern)
+		for _, remote := range context.remotes {
+			if matched, _ := path.Match(pattern, remote); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Moves commands scoped to the current directory or repository to the top.
+// Commands scoped elsewhere are left out unless all is set.
+func applyScopes(commands []Command, all bool) []Command {
+	context := getScopeContext()
+	var active, rest []Command
+	for _, command := range commands {
+		switch {
+		case !isScoped(command):
+			rest = append(rest, command)
+		case context.matches(command):
+			active = append(active, command)
+		case all:
+			rest = append(rest, command)
+		}
+	}
+	return append(active, rest...)
+}
+
+// Returns a short description of command's scope
+func formatScope(command Command) string {
+	if !isScoped(command) {
+		return ""
+	}
+	return strings.Join(append(append([]string(nil), command.Scope.Paths...), command.Scope.Remotes...), ", ")
+}
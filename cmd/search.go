@@ -0,0 +1,65 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Returns a function which checks if text matches pattern, as configured by
+// search.mode: a regular expression, a case insensitive substring, or
+// characters of pattern appearing in order
+func newCommandMatcher(pattern string) (func(text string) bool, error) {
+	switch mode := viper.GetString("search.mode"); mode {
+	case "", "regex":
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	case "substring":
+		pattern = strings.ToLower(pattern)
+		return func(text string) bool {
+			return strings.Contains(strings.ToLower(text), pattern)
+		}, nil
+	case "fuzzy":
+		pattern = strings.ToLower(strings.Join(strings.Fields(pattern), ""))
+		return func(text string) bool {
+			remaining := []rune(pattern)
+			for _, r := range strings.ToLower(text) {
+				if len(remaining) == 0 {
+					break
+				}
+				if r == remaining[0] {
+					remaining = remaining[1:]
+				}
+			}
+			return len(remaining) == 0
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid search.mode %q (use regex, substring or fuzzy)", mode)
+	}
+}
@@ -0,0 +1,283 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	syncDirName = "sync"
+	syncGistID  string
+	syncBackend string
+)
+
+// A remote location commands can be synced with
+type syncRemote interface {
+	// Name identifies the remote in sync state files
+	Name() string
+	// Fetch returns remote commands, or nil if remote has none yet, with a
+	// version tag of them
+	Fetch() (*Commands, string, error)
+	// Store replaces remote commands if they are still at version, or
+	// creates them if version is empty. Returns errRemoteChanged otherwise.
+	Store(commands *Commands, version string) error
+}
+
+// Returned by Store when remote commands changed since they were fetched
+var errRemoteChanged = errors.New("remote commands changed since they were fetched")
+
+// Number of times sync is retried when remote changes during sync
+var maxSyncAttempts = 3
+
+// Returns name of the remote file commands of the library in use are synced
+// to, so that libraries can share a gist or a WebDAV collection
+func getSyncFileName() string {
+	return filepath.Base(getLibraryFileName(getCurrentLibrary()))
+}
+
+// Returns path of the file holding commands as they were at the last sync
+// with remote. It is the common ancestor of a three-way merge.
+func getSyncBasePath(remote syncRemote) (string, error) {
+	stateDirPath, err := getStateDirPath()
+	if err != nil {
+		return "", err
+	}
+	name := remote.Name()
+	if library := getCurrentLibrary(); library != defaultLibrary {
+		name += "-" + library
+	}
+	return filepath.Join(stateDirPath, syncDirName, name+".json"), nil
+}
+
+// Returns commands as they were at the last sync with remote
+func readSyncBase(remote syncRemote) ([]Command, error) {
+	basePath, err := getSyncBasePath(remote)
+	if err != nil {
+		return nil, err
+	}
+	file, err := ioutil.ReadFile(basePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var base Commands
+	if err = json.Unmarshal(file, &base); err != nil {
+		return nil, err
+	}
+	return base.Commands, nil
+}
+
+// Saves commands as the common ancestor for the next sync with remote
+func writeSyncBase(remote syncRemote, commands []Command) error {
+	basePath, err := getSyncBasePath(remote)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(basePath), 0755); err != nil {
+		return err
+	}
+	file, err := json.Marshal(Commands{Commands: commands})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(basePath, file, 0644)
+}
+
+// Merges remote commands into local ones and, if push is set, uploads the
+// result. Changes on both sides are combined with a three-way merge against
+// the state of the last sync. Sync starts over if remote changes meanwhile.
+func syncCommands(remote syncRemote, push bool) error {
+	if err := checkConflictsResolved([]string{getCurrentLibrary()}); err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		err := syncCommandsOnce(remote, push)
+		if err != errRemoteChanged || attempt == maxSyncAttempts {
+			return err
+		}
+		fmt.Println("Remote changed during sync, retrying")
+	}
+}
+
+func syncCommandsOnce(remote syncRemote, push bool) error {
+	local, err := getOrCreateCommands()
+	if err != nil {
+		return err
+	}
+	ensureCommandIDs(local)
+	base, err := readSyncBase(remote)
+	if err != nil {
+		return err
+	}
+	remoteCommands, version, err := remote.Fetch()
+	if err != nil {
+		return err
+	}
+
+	merged := local.Commands
+	if remoteCommands != nil {
+		alignCommandIDs(remoteCommands.Commands, local.Commands)
+		var conflicts []mergeConflict
+		merged, conflicts = mergeCommands(base, local.Commands, remoteCommands.Commands)
+		merged, err = resolveConflicts(merged, conflicts, conflictMode, getCurrentLibrary())
+		if err != nil {
+			return err
+		}
+	} else if !push {
+		fmt.Println("Remote has no commands yet")
+		return nil
+	}
+
+	// upload first, so local commands are untouched if remote has changed
+	if push {
+		if err = remote.Store(&Commands{Commands: merged}, version); err != nil {
+			return err
+		}
+	}
+	if err = writeCommandsToFile(Commands{Commands: merged}); err != nil {
+		return err
+	}
+	if err = commitStoreChange("Merge commands from " + remote.Name()); err != nil {
+		return err
+	}
+	if !push {
+		fmt.Printf("%d command(s) pulled from %s\n", len(merged), remote.Name())
+		return writeSyncBase(remote, remoteCommands.Commands)
+	}
+	fmt.Printf("%d command(s) pushed to %s\n", len(merged), remote.Name())
+	return writeSyncBase(remote, merged)
+}
+
+// Returns the remote selected by flags and config
+func getSyncRemote() (syncRemote, error) {
+	backend := syncBackend
+	if backend == "" {
+		backend = viper.GetString("sync.backend")
+	}
+	switch backend {
+	case "", "gist":
+		if syncGistID != "" {
+			if err := saveConfigValue("sync.gist.id", syncGistID); err != nil {
+				return nil, err
+			}
+		}
+		return newGistRemote(), nil
+	case "webdav":
+		return newWebDAVRemote()
+	case "s3":
+		return newS3Remote()
+	}
+	return nil, fmt.Errorf("unknown sync backend %q (use gist, webdav or s3)", backend)
+}
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Syncs your saved commands with a git remote, GitHub gist, WebDAV or S3",
+	Long: `When store.backend is "git", sync fetches store.git.remote, rebases your
+changes onto it and pushes the result.
+
+push and pull sync your saved commands with the remote selected by --backend
+or sync.backend:
+
+  gist    a secret GitHub gist, created on the first push. Its ID is saved to
+          the config file and the token is read from the environment variable
+          named by sync.gist.token_env (GITHUB_TOKEN by default).
+  webdav  the file at sync.webdav.url
+  s3      the object sync.s3.key in sync.s3.bucket of an S3 compatible storage`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !isGitStore() {
+			cmd.Help()
+			return
+		}
+		// check if app directory exists
+		isAppDirExists, err := checkIfAppDirExists()
+		if err != nil || isAppDirExists == false {
+			// if app directory does not exist, call init command
+			initCmd.Run(cmd, args)
+			return
+		}
+		if err = syncGitStore(); err != nil {
+			fmt.Println("sync error:", err)
+			return
+		}
+	},
+}
+
+// syncPushCmd represents the sync push command
+var syncPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Merges remote changes and uploads your commands",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runSync(cmd, args, true)
+	},
+}
+
+// syncPullCmd represents the sync pull command
+var syncPullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Merges remote changes into your commands",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runSync(cmd, args, false)
+	},
+}
+
+func runSync(cmd *cobra.Command, args []string, push bool) {
+	// check if app directory exists
+	isAppDirExists, err := checkIfAppDirExists()
+	if err != nil || isAppDirExists == false {
+		// if app directory does not exist, call init command
+		initCmd.Run(cmd, args)
+		return
+	}
+	remote, err := getSyncRemote()
+	if err != nil {
+		fmt.Println("sync error:", err)
+		return
+	}
+	if err = syncCommands(remote, push); err != nil {
+		fmt.Println("sync error:", err)
+		return
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.AddCommand(syncPushCmd)
+	syncCmd.AddCommand(syncPullCmd)
+	syncCmd.PersistentFlags().StringVar(&conflictMode, "conflicts", conflictModeAsk, "how to resolve conflicts: ask, file, local or remote")
+	syncCmd.PersistentFlags().StringVar(&syncBackend, "backend", "", "remote for push and pull: gist, webdav or s3 (default is sync.backend or gist)")
+	syncCmd.PersistentFlags().StringVar(&syncGistID, "gist-id", "", "ID of an existing gist to sync with (saved to config)")
+}
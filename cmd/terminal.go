@@ -0,0 +1,92 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+var (
+	defaultTerminalWidth  = 80
+	defaultTerminalHeight = 24
+	defaultPager          = "less -FRX"
+)
+
+// Checks if stdout is attached to a terminal
+func isStdoutTerminal() bool {
+	fd := os.Stdout.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// Checks if stdin is attached to a terminal
+func isStdinTerminal() bool {
+	fd := os.Stdin.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// Returns width and height of the terminal attached to stdout. COLUMNS and
+// LINES environment variables take precedence over the detected size.
+func getTerminalSize() (int, int) {
+	width, height := getWindowSize()
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		width = columns
+	}
+	if lines, err := strconv.Atoi(os.Getenv("LINES")); err == nil && lines > 0 {
+		height = lines
+	}
+	if width <= 0 {
+		width = defaultTerminalWidth
+	}
+	if height <= 0 {
+		height = defaultTerminalHeight
+	}
+	return width, height
+}
+
+// Prints output to stdout, through $PAGER if it does not fit on the screen
+func printWithPager(output string) {
+	if !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	_, height := getTerminalSize()
+	if !isStdoutTerminal() || strings.Count(output, "\n") < height {
+		os.Stdout.WriteString(output)
+		return
+	}
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = defaultPager
+	}
+	pagerCmd := exec.Command("sh", "-c", pager)
+	pagerCmd.Stdin = strings.NewReader(output)
+	pagerCmd.Stdout = os.Stdout
+	pagerCmd.Stderr = os.Stderr
+	if err := pagerCmd.Run(); err != nil {
+		// fall back to plain output if pager can not be started
+		os.Stdout.WriteString(output)
+	}
+}
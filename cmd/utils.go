@@ -22,11 +22,15 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -36,21 +40,59 @@ import (
 
 var (
 	version                               = "0.1.0"
-	appDirName                            = ".katip"
 	commandsFileName                      = "commands.json"
 	warningCommandsFileNotExist           = "No commands to show. Add one by 'katip new'"
 	confirmationTextForDeleteCommand      = "Command will be removed"
 	confirmationTextForRunCommand         = "Execute?"
-	confirmationTextForDeleteAppDirectory = "[CRITICAL] Remove everything inside ~/.katip ?"
+	confirmationTextForDeleteAppDirectory = "[CRITICAL] Remove everything inside %s ?"
 )
 
 type Command struct {
-	Command     string `json:"command"`
-	Description string `json:"description"`
-	Alias       string `json:"alias"`
+	ID           string            `json:"id,omitempty" yaml:"id,omitempty"`
+	Command      string            `json:"command" yaml:"command"`
+	Description  string            `json:"description" yaml:"description"`
+	Alias        string            `json:"alias" yaml:"alias,omitempty"`
+	Tags         []string          `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Variables    map[string]string `json:"variables,omitempty" yaml:"variables,omitempty"`
+	Placeholders []string          `json:"placeholders,omitempty" yaml:"placeholders,omitempty"` // <name>s asked for when run
+	Scope        *Scope            `json:"scope,omitempty" yaml:"scope,omitempty"`
+	Interpreter  string            `json:"interpreter,omitempty" yaml:"interpreter,omitempty"`
+	FailFast     bool              `json:"fail_fast,omitempty" yaml:"fail_fast,omitempty"`
+	Steps        []WorkflowStep    `json:"steps,omitempty" yaml:"steps,omitempty"`
+	Runbook      string            `json:"runbook,omitempty" yaml:"runbook,omitempty"`
+	Workdir      string            `json:"workdir,omitempty" yaml:"workdir,omitempty"`
+	Env          map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	EnvFile      string            `json:"env_file,omitempty" yaml:"env_file,omitempty"`
+	Timeout      string            `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Retries      int               `json:"retries,omitempty" yaml:"retries,omitempty"`
+	RetryDelay   string            `json:"retry_delay,omitempty" yaml:"retry_delay,omitempty"`
+	Capture      bool              `json:"capture,omitempty" yaml:"capture,omitempty"`
+	CreatedAt    time.Time         `json:"created_at" yaml:"created_at,omitempty"`
+	LastUsedAt   time.Time         `json:"last_used_at" yaml:"last_used_at,omitempty"`
+	UsageCount   int               `json:"usage_count,omitempty" yaml:"usage_count,omitempty"`
+	Layer        string            `json:"-" yaml:"-"` // source the command is read from, not saved
+	Library      string            `json:"-" yaml:"-"` // library a user command is read from, not saved
 }
 type Commands struct {
-	Commands []Command `json:"commands"`
+	Commands []Command `json:"commands" yaml:"commands"`
+}
+
+// Encodes command, leaving out the times which are not known instead of
+// saving them as zero times
+func (command Command) MarshalJSON() ([]byte, error) {
+	type plainCommand Command
+	var createdAt, lastUsedAt *time.Time
+	if !command.CreatedAt.IsZero() {
+		createdAt = &command.CreatedAt
+	}
+	if !command.LastUsedAt.IsZero() {
+		lastUsedAt = &command.LastUsedAt
+	}
+	return json.Marshal(struct {
+		plainCommand
+		CreatedAt  *time.Time `json:"created_at,omitempty"`
+		LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	}{plainCommand(command), createdAt, lastUsedAt})
 }
 
 // Checks if app directory is exists
@@ -86,22 +128,13 @@ func getHomeDirPath() (string, error) {
 	return home, nil
 }
 
-// Returns application directory path
-func getAppDirPath() (string, error) {
-	homeDir, err := getHomeDirPath()
-	if err != nil {
-		return "", err
-	}
-	return homeDir + "/" + appDirName, nil
-}
-
-// Returns commands file path
+// Returns commands file path of the library in use
 func getCommandsFilePath() (string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
+	library := getCurrentLibrary()
+	if err := checkLibraryExists(library); err != nil {
 		return "", err
 	}
-	return homeDir + "/" + appDirName + "/" + commandsFileName, nil
+	return getLibraryCommandsFilePath(library)
 }
 
 // Returns saved commands
@@ -116,12 +149,37 @@ func getCommands() (*Commands, error) {
 	if err != nil {
 		return nil, err
 	}
+	if existingCommands == nil {
+		existingCommands = &Commands{}
+	}
+	ensureCommandIDs(existingCommands)
 	return existingCommands, nil
 }
 
+// Returns saved commands, creating an empty commands file if there is none
+func getOrCreateCommands() (*Commands, error) {
+	if !checkIfCommandsFileExists() {
+		if err := createCommandsFile(); err != nil {
+			return nil, err
+		}
+	}
+	commandsFilePath, err := getCommandsFilePath()
+	if err != nil {
+		return nil, err
+	}
+	fileStat, err := os.Stat(commandsFilePath)
+	if err != nil {
+		return nil, err
+	}
+	if fileStat.Size() == 0 {
+		return &Commands{}, nil
+	}
+	return getCommands()
+}
+
 // Creates app directory
 func createAppDirectory(dirPath string) error {
-	fmt.Printf("Initializing environment in ~/%s directory\n", appDirName)
+	fmt.Printf("Initializing environment in %s directory\n", dirPath)
 	s := spinner.New(spinner.CharSets[9], 100*time.Millisecond)
 	s.Start()
 	time.Sleep(2 * time.Second)
@@ -140,6 +198,9 @@ func createCommandsFile() error {
 	if err != nil {
 		return err
 	}
+	if err = os.MkdirAll(filepath.Dir(commandsFilePath), 0755); err != nil {
+		return err
+	}
 	f, err := os.OpenFile(commandsFilePath, os.O_WRONLY|os.O_CREATE, 0666)
 	if err != nil {
 		return err
@@ -148,13 +209,87 @@ func createCommandsFile() error {
 	return nil
 }
 
+// Returns a new random identifier for a command
+func newCommandID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%08x", time.Now().UnixNano()&0xffffffff)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Returns an identifier for a command saved without one, derived from its
+// content
+func getDerivedCommandID(command Command) string {
+	return sha256Hex([]byte(getCommandText(command)))[:8]
+}
+
+// Assigns an identifier to every command that does not have one yet. They
+// are derived from the commands, so a file read many times gives the same
+// identifiers until a command which changes the file saves them.
+func ensureCommandIDs(commands *Commands) {
+	used := map[string]bool{}
+	for _, command := range commands.Commands {
+		used[command.ID] = true
+	}
+	for i := range commands.Commands {
+		if commands.Commands[i].ID != "" {
+			continue
+		}
+		id := getDerivedCommandID(commands.Commands[i])
+		for n := 1; used[id]; n++ {
+			// commands saved twice get the identifier of their n-th copy
+			id = sha256Hex([]byte(getCommandText(commands.Commands[i]) + "\n" + strconv.Itoa(n)))[:8]
+		}
+		used[id] = true
+		commands.Commands[i].ID = id
+	}
+}
+
+// Returns commands of library, giving the ones without an identifier one
+// in memory
+func readLibraryCommands(library string) (*Commands, error) {
+	commandsFilePath, err := getLibraryCommandsFilePath(library)
+	if err != nil {
+		return nil, err
+	}
+	commands, err := readCommandsFile(commandsFilePath)
+	if err != nil {
+		return nil, err
+	}
+	ensureCommandIDs(commands)
+	return commands, nil
+}
+
+// Splits comma separated tags input into a slice
+func parseTags(input string) []string {
+	var tags []string
+	for _, tag := range strings.Split(input, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
 // Writes commands to commands file
 func writeCommandsToFile(commands Commands) error {
+	library := getCurrentLibrary()
+	if err := checkLibraryExists(library); err != nil {
+		return err
+	}
+	return writeLibraryCommands(library, commands)
+}
+
+// Writes commands to commands file of library
+func writeLibraryCommands(library string, commands Commands) error {
+	ensureCommandIDs(&commands)
 	commandsJSON, err := json.MarshalIndent(commands, "", "")
 	if err != nil {
 		return err
 	}
-	commandsFilePath, err := getCommandsFilePath()
+	commandsFilePath, err := getLibraryCommandsFilePath(library)
 	if err != nil {
 		return err
 	}
@@ -165,6 +300,72 @@ func writeCommandsToFile(commands Commands) error {
 	return nil
 }
 
+// Returns index of the saved command with the same command text, or -1
+func findCommandIndex(commands *Commands, commandText string) int {
+	commandText = strings.TrimSpace(commandText)
+	for i, command := range commands.Commands {
+		if strings.TrimSpace(command.Command) == commandText {
+			return i
+		}
+	}
+	return -1
+}
+
+// Adds the commands which are not saved yet and returns the added ones. An
+// alias which is already taken gets a number, so that aliases stay unique.
+func mergeNewCommands(commands *Commands, newCommands []Command) []Command {
+	var added []Command
+	aliases := map[string]bool{}
+	for _, command := range commands.Commands {
+		aliases[command.Alias] = true
+	}
+	for _, command := range newCommands {
+		if strings.TrimSpace(command.Command) == "" || findCommandIndex(commands, command.Command) >= 0 {
+			continue
+		}
+		if alias := command.Alias; alias != "" && aliases[alias] {
+			for n := 2; aliases[command.Alias]; n++ {
+				command.Alias = alias + "-" + strconv.Itoa(n)
+			}
+			fmt.Fprintf(os.Stderr, "warning : alias %s is taken, %s is used instead\n", alias, command.Alias)
+		}
+		aliases[command.Alias] = true
+		if command.ID == "" {
+			command.ID = newCommandID()
+		}
+		if command.CreatedAt.IsZero() {
+			command.CreatedAt = time.Now()
+		}
+		commands.Commands = append(commands.Commands, command)
+		added = append(added, command)
+	}
+	return added
+}
+
+// Updates usage statistics of command in user's commands file. Commands of
+// read-only layers are not tracked.
+func markCommandAsUsed(command Command) error {
+	if command.Layer != "" && command.Layer != userLayer {
+		return nil
+	}
+	library := command.Library
+	if library == "" {
+		library = getCurrentLibrary()
+	}
+	commands, err := readLibraryCommands(library)
+	if err != nil {
+		return err
+	}
+	for i := range commands.Commands {
+		if commands.Commands[i].ID == command.ID {
+			commands.Commands[i].LastUsedAt = time.Now()
+			commands.Commands[i].UsageCount++
+			return writeLibraryCommands(library, *commands)
+		}
+	}
+	return nil
+}
+
 // Search commands that contains concatenated string of args
 func searchCommands(args []string) ([]string, error) {
 	// concatenate args into the single string
@@ -191,20 +392,166 @@ func searchCommands(args []string) ([]string, error) {
 	return matches, nil
 }
 
-// Prints commands as table
-func printCommandsAsTable(commands *Commands) {
+// Header titles of the columns that can be shown in commands table
+var commandColumnTitles = map[string]string{
+	"id":          "ID",
+	"command":     "Command",
+	"description": "Description",
+	"alias":       "Alias",
+	"tags":        "Tags",
+	"created":     "Created",
+	"last-used":   "Last Used",
+	"usage":       "Usage",
+	"layer":       "Layer",
+	"library":     "Library",
+	"scope":       "Scope",
+	"interpreter": "Interpreter",
+	"workdir":     "Workdir",
+	"env":         "Env",
+}
+
+// Columns shown in commands table by default
+var defaultCommandColumns = []string{"command", "description", "alias"}
+
+// Minimum width of the command column when it is truncated to fit terminal
+var minCommandColumnWidth = 20
+
+// Number of lines of a script shown in a table
+var maxTableScriptLines = 8
+
+// Returns value of the given column for command
+func getCommandColumnValue(command Command, column string) string {
+	switch column {
+	case "id":
+		return command.ID
+	case "command":
+		return getCommandText(command)
+	case "description":
+		return command.Description
+	case "alias":
+		return command.Alias
+	case "tags":
+		return strings.Join(command.Tags, ", ")
+	case "created":
+		return formatCommandTime(command.CreatedAt)
+	case "last-used":
+		return formatCommandTime(command.LastUsedAt)
+	case "usage":
+		return strconv.Itoa(command.UsageCount)
+	case "layer":
+		return command.Layer
+	case "library":
+		return command.Library
+	case "scope":
+		return formatScope(command)
+	case "interpreter":
+		return command.Interpreter
+	case "workdir":
+		return command.Workdir
+	case "env":
+		env := formatEnv(command.Env)
+		if command.EnvFile != "" {
+			env = strings.TrimSpace(command.EnvFile + " " + env)
+		}
+		return env
+	}
+	return ""
+}
+
+// Formats timestamps of commands for tables
+func formatCommandTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// Truncates s to width runes, marking truncation with an ellipsis
+func truncateString(s string, width int) string {
+	runes := []rune(s)
+	if width <= 0 || len(runes) <= width {
+		return s
+	}
+	if width <= 3 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-3]) + "..."
+}
+
+// Truncates each line of a script to width and highlights it. Long scripts
+// are cut after maxTableScriptLines lines.
+func formatScriptCell(script string, width int) string {
+	lines := strings.Split(strings.TrimRight(script, "\n"), "\n")
+	hidden := 0
+	if len(lines) > maxTableScriptLines {
+		hidden = len(lines) - maxTableScriptLines + 1
+		lines = lines[:maxTableScriptLines-1]
+	}
+	for i, line := range lines {
+		lines[i] = highlightCommand(truncateString(line, width))
+	}
+	if hidden > 0 {
+		lines = append(lines, fmt.Sprintf("... %d more lines", hidden))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Renders commands as table with given columns. If width is greater than
+// zero, the command column is truncated so that the table fits in width.
+func renderCommandsTable(commands []Command, columns []string, width int) string {
+	// calculate how much space is left for the command column
+	commandWidth := 0
+	if width > 0 {
+		used := 3*len(columns) + 1
+		for _, column := range columns {
+			if column == "command" {
+				continue
+			}
+			columnWidth := len([]rune(commandColumnTitles[column]))
+			for _, command := range commands {
+				if w := len([]rune(getCommandColumnValue(command, column))); w > columnWidth {
+					columnWidth = w
+				}
+			}
+			used += columnWidth
+		}
+		commandWidth = width - used
+		if commandWidth < minCommandColumnWidth {
+			commandWidth = minCommandColumnWidth
+		}
+	}
 
 	// convert commands to table.Row type
+	var header table.Row
+	for _, column := range columns {
+		header = append(header, commandColumnTitles[column])
+	}
 	var commandRow []table.Row
-	for _, command := range commands.Commands {
-		commandRow = append(commandRow, table.Row{command.Command, command.Description, command.Alias})
+	for _, command := range commands {
+		var row table.Row
+		for _, column := range columns {
+			value := getCommandColumnValue(command, column)
+			if column == "command" {
+				value = formatScriptCell(value, commandWidth)
+			}
+			row = append(row, value)
+		}
+		commandRow = append(commandRow, row)
 	}
 	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
 	t.Style().Options.SeparateRows = true
-	t.AppendHeader(table.Row{"Command", "Description", "Alias"})
+	t.AppendHeader(header)
 	t.AppendRows(commandRow)
-	t.Render()
+	return t.Render()
+}
+
+// Prints commands as table, fitted to terminal width and paged if needed
+func printCommandsAsTable(commands []Command, columns []string) {
+	width := 0
+	if isStdoutTerminal() {
+		width, _ = getTerminalSize()
+	}
+	printWithPager(renderCommandsTable(commands, columns, width))
 	return
 }
 
@@ -214,7 +561,7 @@ func printCommandsAsTableWithIndexes(commands *Commands) {
 	// convert commands to table.Row type
 	var commandRow []table.Row
 	for index, command := range commands.Commands {
-		commandRow = append(commandRow, table.Row{index + 1, command.Command, command.Description, command.Alias})
+		commandRow = append(commandRow, table.Row{index + 1, highlightCommand(getCommandText(command)), command.Description, command.Alias})
 	}
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
@@ -233,3 +580,12 @@ func isIntInSlice(i int, slice []int) bool {
 	}
 	return false
 }
+
+func isStringInSlice(s string, slice []string) bool {
+	for _, a := range slice {
+		if a == s {
+			return true
+		}
+	}
+	return false
+}
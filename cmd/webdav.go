@@ -0,0 +1,84 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Creates WebDAV remote from sync.webdav settings. Like other secrets, the
+// password is read from the environment variable named in the config.
+func newWebDAVRemote() (syncRemote, error) {
+	fileURL := viper.GetString("sync.webdav.url")
+	if fileURL == "" {
+		return nil, fmt.Errorf("no WebDAV url configured, set sync.webdav.url")
+	}
+	if strings.HasSuffix(fileURL, "/") {
+		fileURL += getSyncFileName()
+	}
+	username := viper.GetString("sync.webdav.username")
+	password := os.Getenv(viper.GetString("sync.webdav.password_env"))
+	remote := newHTTPFileRemote("webdav", fileURL)
+	remote.authorize = func(req *http.Request, payload []byte) error {
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		return nil
+	}
+	remote.prepare = createWebDAVCollections
+	return remote, nil
+}
+
+// Creates the collections containing the remote file. Collections which
+// already exist are left as they are.
+func createWebDAVCollections(r *httpFileRemote) error {
+	fileURL, err := url.Parse(r.url)
+	if err != nil {
+		return err
+	}
+	dir := path.Dir(fileURL.Path)
+	var dirs []string
+	for dir != "/" && dir != "." {
+		dirs = append([]string{dir}, dirs...)
+		dir = path.Dir(dir)
+	}
+	for _, dir := range dirs {
+		collectionURL := *fileURL
+		collectionURL.Path = dir + "/"
+		resp, body, err := r.do("MKCOL", collectionURL.String(), nil, nil)
+		if err != nil {
+			return err
+		}
+		// 405 means the collection exists
+		if resp.StatusCode >= 300 && resp.StatusCode != http.StatusMethodNotAllowed {
+			return httpStatusError("MKCOL", collectionURL.String(), resp, body)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestWebDAVRemote(t *testing.T) {
+	fake, server := newFakeFileServer(t, true)
+	viper.Set("sync.webdav.url", server.URL+"/dav/katip/")
+	viper.Set("sync.webdav.username", "user")
+	viper.Set("sync.webdav.password_env", "KATIP_TEST_WEBDAV_PASSWORD")
+	t.Setenv("KATIP_TEST_WEBDAV_PASSWORD", "secret")
+	defer viper.Set("sync.webdav.url", "")
+	defer viper.Set("sync.webdav.username", "")
+
+	remote, err := newWebDAVRemote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testHTTPFileRemote(t, remote, fake, "/dav/katip/"+getSyncFileName())
+	if !fake.collections["/dav/katip"] {
+		t.Error("collections of the file are not created")
+	}
+	for _, req := range fake.requests {
+		if username, password, ok := req.BasicAuth(); !ok || username != "user" || password != "secret" {
+			t.Fatalf("%s %s is sent without credentials", req.Method, req.URL)
+		}
+	}
+}
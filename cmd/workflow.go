@@ -0,0 +1,237 @@
+/*
+Copyright © 2020 Fatih Ermiş <ermissaim@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Values of on_failure
+const (
+	onFailureStop     = "stop"
+	onFailureContinue = "continue"
+)
+
+// WorkflowStep runs a saved command as part of a workflow
+type WorkflowStep struct {
+	Name      string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Command   string            `json:"command" yaml:"command"` // ID or alias of a saved command
+	Bindings  map[string]string `json:"bindings,omitempty" yaml:"bindings,omitempty"`
+	OnFailure string            `json:"on_failure,omitempty" yaml:"on_failure,omitempty"`
+}
+
+// Template written to the editor when a new workflow is created
+var workflowTemplate = `# Steps of the workflow, run in order. command is the ID or alias of a saved
+# command. bindings fill its placeholders, and may use the output of earlier
+# steps as <prev.output> or <steps.NAME.output>. on_failure is stop or
+# continue.
+#
+# - command: build
+# - name: version
+#   command: next-version
+# - command: tag
+#   bindings:
+#     tag: v<steps.version.output>
+#   on_failure: continue
+`
+
+var (
+	stepSucceededColor = color.New(color.FgGreen)
+	stepFailedColor    = color.New(color.FgRed)
+)
+
+// Checks if command is a workflow of other commands
+func isWorkflow(command Command) bool {
+	return len(command.Steps) > 0
+}
+
+// Returns the text of command shown and searched. A workflow is shown as
+// its steps, and a runbook as its title.
+func getCommandText(command Command) string {
+	if command.Runbook != "" {
+		title, _ := parseRunbook(command.Runbook)
+		return "runbook: " + title
+	}
+	if !isWorkflow(command) {
+		return command.Command
+	}
+	var names []string
+	for _, step := range command.Steps {
+		names = append(names, step.Command)
+	}
+	return "workflow: " + strings.Join(names, " -> ")
+}
+
+// Returns name of step, used in progress and to refer to its output
+func getStepName(step WorkflowStep) string {
+	if step.Name != "" {
+		return step.Name
+	}
+	return step.Command
+}
+
+// Parses steps of a workflow written in YAML
+func parseWorkflowSteps(text string) ([]WorkflowStep, error) {
+	var steps []WorkflowStep
+	if err := yaml.UnmarshalStrict([]byte(text), &steps); err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("workflow has no steps")
+	}
+	for i, step := range steps {
+		if strings.TrimSpace(step.Command) == "" {
+			return nil, fmt.Errorf("step %d has no command", i+1)
+		}
+		switch step.OnFailure {
+		case "", onFailureStop, onFailureContinue:
+		default:
+			return nil, fmt.Errorf("step %d: invalid on_failure %q (use stop or continue)", i+1, step.OnFailure)
+		}
+	}
+	return steps, nil
+}
+
+// Returns the command with ID or alias reference
+func findCommandByReference(commands []Command, reference string) (Command, bool) {
+	for _, command := range commands {
+		if command.ID == reference {
+			return command, true
+		}
+	}
+	for _, command := range commands {
+		if command.Alias != "" && command.Alias == reference {
+			return command, true
+		}
+	}
+	return Command{}, false
+}
+
+// Runs steps of workflow one by one, printing the progress and the output of
+// steps to stdout and stderr. The output of each step is passed to the
+// following ones as placeholder values.
+func runWorkflow(workflow Command, failFast bool, stdout, stderr io.Writer) error {
+	commands, err := getAllCommands()
+	if err != nil {
+		return err
+	}
+	outputs := map[string]string{}
+	failed := 0
+	for i, step := range workflow.Steps {
+		name := getStepName(step)
+		fmt.Fprintf(stdout, "[%d/%d] %s\n", i+1, len(workflow.Steps), name)
+		start := time.Now()
+		output, err := runWorkflowStep(commands.Commands, step, outputs, failFast, stdout, stderr)
+		outputs["prev.output"] = output
+		outputs["steps."+name+".output"] = output
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if err == nil {
+			fmt.Fprintln(stdout, stepSucceededColor.Sprintf("✓ %s (%s)", name, elapsed))
+			continue
+		}
+		failed++
+		fmt.Fprintln(stdout, stepFailedColor.Sprintf("✗ %s: %v (%s)", name, err, elapsed))
+		if step.OnFailure != onFailureContinue {
+			return fmt.Errorf("workflow stopped at step %d of %d", i+1, len(workflow.Steps))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d steps failed", failed, len(workflow.Steps))
+	}
+	return nil
+}
+
+// Returns the command step runs, with its placeholders filled by bindings
+// and outputs of earlier steps, or asked for. Variable commands suggesting
+// values are run only when suggest is set, previews must not run anything.
+func resolveWorkflowStep(commands []Command, step WorkflowStep, outputs map[string]string, suggest bool) (Command, error) {
+	command, ok := findCommandByReference(commands, step.Command)
+	if !ok {
+		return command, fmt.Errorf("no saved command with ID or alias %q", step.Command)
+	}
+	if isWorkflow(command) {
+		return command, fmt.Errorf("%s is a workflow, workflows cannot be nested", step.Command)
+	}
+	if command.Runbook != "" {
+		return command, fmt.Errorf("%s is a runbook, runbooks cannot be steps", step.Command)
+	}
+	values := map[string]string{}
+	for name, value := range outputs {
+		values[name] = value
+	}
+	for name, value := range step.Bindings {
+		rendered, err := renderPlaceholders(value, nil, outputs, nil, false)
+		if err != nil {
+			return command, err
+		}
+		values[name] = rendered
+	}
+	var variables map[string]string
+	if suggest {
+		variables = command.Variables
+	}
+	rendered, err := renderPlaceholders(command.Command, command.Placeholders, values, variables, true)
+	if err != nil {
+		return command, err
+	}
+	command.Command = rendered
+	return command, nil
+}
+
+// Returns placeholder values standing for outputs of steps, which are only
+// known once they run. Placeholders of outputs are kept as they are.
+func getSymbolicOutputs(workflow Command) map[string]string {
+	outputs := map[string]string{"prev.output": "<prev.output>"}
+	for _, step := range workflow.Steps {
+		name := "steps." + getStepName(step) + ".output"
+		outputs[name] = "<" + name + ">"
+	}
+	return outputs
+}
+
+// Runs a step and returns its output, which is also printed as it comes
+func runWorkflowStep(commands []Command, step WorkflowStep, outputs map[string]string, failFast bool, stdout, stderr io.Writer) (string, error) {
+	command, err := resolveWorkflowStep(commands, step, outputs, true)
+	if err != nil {
+		return "", err
+	}
+	options, err := getRunOptions(command)
+	if err != nil {
+		return "", err
+	}
+	options.failFast = options.failFast || failFast
+	var output bytes.Buffer
+	err = runWithRetries(command, options, io.MultiWriter(stdout, &output), stderr)
+	if err == nil {
+		if err := markCommandAsUsed(command); err != nil {
+			fmt.Println("error while saving usage statistics:", err)
+		}
+	}
+	return strings.TrimRight(output.String(), "\r\n"), err
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseWorkflowSteps(t *testing.T) {
+	tests := []struct {
+		text  string
+		steps []WorkflowStep
+		err   string
+	}{
+		{
+			text:  "- command: build\n- command: deploy\n  on_failure: continue\n",
+			steps: []WorkflowStep{{Command: "build"}, {Command: "deploy", OnFailure: onFailureContinue}},
+		},
+		{
+			text: "- name: version\n  command: next-version\n- command: tag\n  bindings:\n    tag: v<steps.version.output>\n",
+			steps: []WorkflowStep{
+				{Name: "version", Command: "next-version"},
+				{Command: "tag", Bindings: map[string]string{"tag": "v<steps.version.output>"}},
+			},
+		},
+		{text: "# only comments\n", err: "workflow has no steps"},
+		{text: "- name: nothing\n", err: "step 1 has no command"},
+		{text: "- command: build\n  on_failure: retry\n", err: `step 1: invalid on_failure "retry"`},
+		{text: "- command: build\n  retries: 3\n", err: "field retries not found"},
+	}
+	for _, test := range tests {
+		steps, err := parseWorkflowSteps(test.text)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("parseWorkflowSteps(%q) error %v, want %q", test.text, err, test.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(steps, test.steps) {
+			t.Errorf("parseWorkflowSteps(%q) = %+v (%v), want %+v", test.text, steps, err, test.steps)
+		}
+	}
+}
+
+func TestFindCommandByReference(t *testing.T) {
+	commands := []Command{
+		{ID: "a1", Alias: "build", Command: "make"},
+		{ID: "build", Alias: "other", Command: "go build"},
+		{ID: "c3", Command: "ls"},
+	}
+	tests := []struct {
+		reference string
+		command   string
+		found     bool
+	}{
+		{"a1", "make", true},
+		{"other", "go build", true},
+		// IDs are matched before aliases
+		{"build", "go build", true},
+		{"c3", "ls", true},
+		{"", "", false},
+		{"deploy", "", false},
+	}
+	for _, test := range tests {
+		command, found := findCommandByReference(commands, test.reference)
+		if found != test.found || command.Command != test.command {
+			t.Errorf("findCommandByReference(%q) = %q %v, want %q %v", test.reference, command.Command, found, test.command, test.found)
+		}
+	}
+}
+
+func TestResolveWorkflowStep(t *testing.T) {
+	commands := []Command{
+		{Alias: "tag", Command: "git tag <tag> -m <message=release>", Placeholders: []string{"tag", "message"}},
+		{Alias: "show", Command: "cat <prev.output> <file>"},
+		{Alias: "both", Steps: []WorkflowStep{{Command: "tag"}}},
+		{Alias: "book", Runbook: "# Book\n"},
+	}
+	outputs := map[string]string{"prev.output": "1.2.3", "steps.version.output": "1.2.3"}
+	tests := []struct {
+		step    WorkflowStep
+		outputs map[string]string
+		input   string
+		command string
+		err     string
+	}{
+		// unbound placeholders are asked for, offering their defaults
+		{
+			step:    WorkflowStep{Command: "tag", Bindings: map[string]string{"tag": "v<steps.version.output>"}},
+			outputs: outputs,
+			input:   "\n",
+			command: "git tag v1.2.3 -m release",
+		},
+		{
+			step:    WorkflowStep{Command: "tag", Bindings: map[string]string{"tag": "v<prev.output>", "message": "<prev.output> is out"}},
+			outputs: outputs,
+			command: "git tag v1.2.3 -m 1.2.3 is out",
+		},
+		// outputs fill placeholders of the command, undeclared ones are kept
+		{step: WorkflowStep{Command: "show"}, outputs: outputs, command: "cat 1.2.3 <file>"},
+		{step: WorkflowStep{Command: "show"}, outputs: getSymbolicOutputs(Command{}), command: "cat <prev.output> <file>"},
+		{step: WorkflowStep{Command: "missing"}, err: `no saved command with ID or alias "missing"`},
+		{step: WorkflowStep{Command: "both"}, err: "workflows cannot be nested"},
+		{step: WorkflowStep{Command: "book"}, err: "runbooks cannot be steps"},
+	}
+	for _, test := range tests {
+		setStdin(t, test.input)
+		command, err := resolveWorkflowStep(commands, test.step, test.outputs, false)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("resolveWorkflowStep(%+v) error %v, want %q", test.step, err, test.err)
+			}
+			continue
+		}
+		if err != nil || command.Command != test.command {
+			t.Errorf("resolveWorkflowStep(%+v) = %q (%v), want %q", test.step, command.Command, err, test.command)
+		}
+	}
+}
+
+func TestGetSymbolicOutputs(t *testing.T) {
+	workflow := Command{Steps: []WorkflowStep{{Command: "build"}, {Name: "version", Command: "next-version"}}}
+	want := map[string]string{
+		"prev.output":          "<prev.output>",
+		"steps.build.output":   "<steps.build.output>",
+		"steps.version.output": "<steps.version.output>",
+	}
+	if outputs := getSymbolicOutputs(workflow); !reflect.DeepEqual(outputs, want) {
+		t.Errorf("getSymbolicOutputs() = %v, want %v", outputs, want)
+	}
+}
+
+func TestRunWorkflow(t *testing.T) {
+	t.Setenv(homeEnvVariable, t.TempDir())
+	if err := createCommandsFile(); err != nil {
+		t.Fatal(err)
+	}
+	commands := Commands{Commands: []Command{
+		{Alias: "version", Command: "echo 1.2.3", Interpreter: "sh"},
+		{Alias: "tag", Command: "echo tagged <tag>", Interpreter: "sh", Placeholders: []string{"tag"}},
+		{Alias: "fail", Command: "exit 3", Interpreter: "sh"},
+	}}
+	if err := writeCommandsToFile(commands); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		steps   []WorkflowStep
+		output  []string
+		skipped string
+		err     string
+	}{
+		{
+			steps:  []WorkflowStep{{Command: "version"}, {Command: "tag", Bindings: map[string]string{"tag": "v<prev.output>"}}},
+			output: []string{"[1/2] version", "1.2.3", "[2/2] tag", "tagged v1.2.3"},
+		},
+		{
+			steps:   []WorkflowStep{{Command: "fail"}, {Command: "version"}},
+			output:  []string{"[1/2] fail"},
+			skipped: "[2/2]",
+			err:     "workflow stopped at step 1 of 2",
+		},
+		{
+			steps:  []WorkflowStep{{Command: "fail", OnFailure: onFailureContinue}, {Command: "version"}},
+			output: []string{"[1/2] fail", "[2/2] version", "1.2.3"},
+			err:    "1 of 2 steps failed",
+		},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		err := runWorkflow(Command{Steps: test.steps}, false, &stdout, &stderr)
+		if (err == nil) != (test.err == "") || err != nil && err.Error() != test.err {
+			t.Errorf("runWorkflow(%+v) error %v, want %q", test.steps, err, test.err)
+		}
+		for _, line := range test.output {
+			if !strings.Contains(stdout.String(), line+"\n") {
+				t.Errorf("runWorkflow(%+v) printed %q, want a line %q", test.steps, stdout.String(), line)
+			}
+		}
+		if test.skipped != "" && strings.Contains(stdout.String(), test.skipped) {
+			t.Errorf("runWorkflow(%+v) printed %q, want no %q", test.steps, stdout.String(), test.skipped)
+		}
+	}
+}